@@ -0,0 +1,159 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// emailThrottle limits how often alert emails are sent.
+type emailThrottle struct {
+	mutex       sync.Mutex
+	interval    time.Duration
+	leadingEdge bool
+	windowStart time.Time
+	suppressed  int
+}
+
+// SetEmailOnLevel sends an alert email for every line written at the level or
+// above, such as LEVEL_ERROR, without changing the call sites. Pass 0 to turn
+// it off, which is the default. Use SetEmailThrottle to keep a burst of errors
+// from flooding the mailbox.
+func SetEmailOnLevel(level int32) {
+	atomic.StoreInt32(&logger.EmailOnLevel, level)
+}
+
+// SetEmailThrottle sends at most one alert email per interval. The alerts raised
+// during the interval are dropped and counted in the next email that is sent.
+// Pass 0 to send every alert, which is the default.
+func SetEmailThrottle(interval time.Duration) {
+	logger.EmailThrottle.mutex.Lock()
+	logger.EmailThrottle.interval = interval
+	logger.EmailThrottle.mutex.Unlock()
+}
+
+// SetEmailThrottleLeadingEdge restarts the throttle interval with every alert,
+// including the ones dropped, instead of only with the ones sent. A steady stream
+// of errors then sends a single email, and the next email goes out for the first
+// alert after the errors have been quiet for the interval, which is the one worth
+// waking up for.
+func SetEmailThrottleLeadingEdge(leadingEdge bool) {
+	logger.EmailThrottle.mutex.Lock()
+	logger.EmailThrottle.leadingEdge = leadingEdge
+	logger.EmailThrottle.mutex.Unlock()
+}
+
+// allow reports if an email can be sent now and how many were dropped since
+// the last one.
+func (emailThrottle *emailThrottle) allow(now time.Time) (bool, int) {
+	emailThrottle.mutex.Lock()
+	defer emailThrottle.mutex.Unlock()
+
+	if emailThrottle.interval > 0 && !emailThrottle.windowStart.IsZero() && now.Sub(emailThrottle.windowStart) < emailThrottle.interval {
+		emailThrottle.suppressed++
+		if emailThrottle.leadingEdge {
+			emailThrottle.windowStart = now
+		}
+		return false, 0
+	}
+
+	suppressed := emailThrottle.suppressed
+	emailThrottle.windowStart = now
+	emailThrottle.suppressed = 0
+
+	return true, suppressed
+}
+
+// sendAlert sends an alert email, and posts it to the webhook when one is
+// configured, unless the throttle drops it.
+func sendAlert(severity string, subject string, message string) {
+	logger.Serialize.Lock()
+	webhook := logger.Webhook != nil
+	email := logger.EmailConfiguration != nil || logger.MailSender != nil
+	logger.Serialize.Unlock()
+
+	if !webhook && !email {
+		// The alert line is written, but nobody is told. Say so once per Start
+		// so the missing configuration is noticed without flooding the log.
+		if atomic.CompareAndSwapInt32(&logger.UnsentAlertWarned, 0, 1) {
+			Warning("main", "Alert", "Subject[%s] : Alerts are not delivered, call ConfigureEmail or ConfigureWebhook", subject)
+		}
+		return
+	}
+
+	allowed, suppressed := logger.EmailThrottle.allow(time.Now())
+	if !allowed {
+		return
+	}
+
+	if suppressed > 0 {
+		message = fmt.Sprintf("%s\nSuppressed[%d] alerts since the last alert\n", strings.TrimRight(message, "\n"), suppressed)
+	}
+
+	if webhook {
+		atomic.AddInt64(&pendingAlerts, 1)
+		go func() {
+			defer atomic.AddInt64(&pendingAlerts, -1)
+			postWebhook(severity, subject, message)
+		}()
+	}
+
+	sendEmail(severity, subject, message)
+}
+
+// emailOnLevel sends an alert for the record when its level meets the level set
+// by SetEmailOnLevel. The alert is sent in the background so the log call doesn't
+// wait on the mail server.
+func (traceLog *traceLog) emailOnLevel(rec Record) {
+	level := atomic.LoadInt32(&traceLog.EmailOnLevel)
+	if level == 0 || rec.Level < level {
+		return
+	}
+
+	// Alerts and panics send their own email and a failed alert must not
+	// alert again.
+	if rec.Tag == traceLog.localTag("ALERT") || rec.Tag == traceLog.localTag("Completed : ALERT") || rec.Tag == traceLog.localTag("PANIC") || rec.Function == "SendEmailException" || rec.Function == "PostWebhook" {
+		return
+	}
+
+	traceLog.Serialize.Lock()
+	rec = traceLog.redact(rec)
+	traceLog.Serialize.Unlock()
+
+	subject := fmt.Sprintf("%s : %s : %s", LevelName(rec.Level), rec.Title, rec.Function)
+	message := recordText(rec) + "\n"
+
+	atomic.AddInt64(&pendingAlerts, 1)
+	go func() {
+		defer atomic.AddInt64(&pendingAlerts, -1)
+		sendAlert(LevelName(rec.Level), subject, message)
+	}()
+}
+
+// pendingAlerts counts the alert emails and webhooks being sent in the background.
+var pendingAlerts int64
+
+// waitForAlerts waits for the alerts being sent in the background to finish. An
+// error is returned if some are still being sent when the timeout expires.
+func waitForAlerts(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		pending := atomic.LoadInt64(&pendingAlerts)
+		if pending <= 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("wait timed out after %v with %d alerts being sent", timeout, pending)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
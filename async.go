@@ -0,0 +1,183 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// defaultDrainTimeout is how long Stop waits for queued lines to be written.
+const defaultDrainTimeout = 5 * time.Second
+
+// OverflowPolicy decides what happens when the asynchronous queue is full.
+type OverflowPolicy int32
+
+const (
+	OverflowBlock      OverflowPolicy = iota // Wait for room in the queue
+	OverflowDropNewest                       // Discard the line being written
+	OverflowDropOldest                       // Discard the oldest queued line
+)
+
+// asyncWriter queues writes for a background goroutine so callers don't
+// wait on slow destinations.
+type asyncWriter struct {
+	queue   chan func()
+	pending int64
+	done    chan struct{}
+}
+
+// SetAsync turns on asynchronous writes using a queue that holds bufferSize lines.
+// Callers return as soon as their line is queued. Use Drain or Stop to make sure
+// the queued lines are written before the program exits. The queue is kept when
+// logging is started again, so it can be set before Start.
+func SetAsync(bufferSize int) {
+	async := &asyncWriter{
+		queue: make(chan func(), bufferSize),
+		done:  make(chan struct{}),
+	}
+	go async.run()
+
+	logger.Serialize.Lock()
+	previous := logger.Async
+	logger.Async = async
+	logger.Serialize.Unlock()
+
+	if previous != nil {
+		previous.drain(defaultDrainTimeout)
+		previous.stop()
+	}
+}
+
+// SetOverflowPolicy sets what happens when the asynchronous queue is full.
+// The default is OverflowBlock.
+func SetOverflowPolicy(policy OverflowPolicy) {
+	atomic.StoreInt32(&logger.OverflowPolicy, int32(policy))
+}
+
+// DroppedLines returns the number of lines discarded because the asynchronous
+// queue was full.
+func DroppedLines() int64 {
+	return atomic.LoadInt64(&logger.DroppedLines)
+}
+
+// Drain waits until every queued line has been written. An error is returned if
+// lines are still queued when the timeout expires. Drain returns immediately
+// when asynchronous writes are not turned on.
+func Drain(timeout time.Duration) error {
+	logger.Serialize.Lock()
+	async := logger.Async
+	logger.Serialize.Unlock()
+
+	if async == nil {
+		return nil
+	}
+
+	return async.drain(timeout)
+}
+
+// stopAsync drains the queue and returns to synchronous writes.
+func (traceLog *traceLog) stopAsync(timeout time.Duration) error {
+	traceLog.Serialize.Lock()
+	async := traceLog.Async
+	traceLog.Serialize.Unlock()
+
+	if async == nil {
+		return nil
+	}
+
+	err := async.drain(timeout)
+
+	traceLog.Serialize.Lock()
+	traceLog.Async = nil
+	traceLog.Serialize.Unlock()
+
+	async.stop()
+	return err
+}
+
+// enqueue queues the write for the background goroutine, applying the
+// overflow policy when the queue is full.
+func (async *asyncWriter) enqueue(write func()) {
+	atomic.AddInt64(&async.pending, 1)
+
+	switch OverflowPolicy(atomic.LoadInt32(&logger.OverflowPolicy)) {
+	case OverflowDropNewest:
+		select {
+		case async.queue <- write:
+		default:
+			async.dropped()
+		}
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case async.queue <- write:
+				return
+			case <-async.done:
+				async.dropped()
+				return
+			default:
+			}
+
+			select {
+			case <-async.queue:
+				async.dropped()
+			default:
+			}
+		}
+
+	default:
+		select {
+		case async.queue <- write:
+		case <-async.done:
+			atomic.AddInt64(&async.pending, -1)
+		}
+	}
+}
+
+// dropped records a line that was discarded instead of written.
+func (async *asyncWriter) dropped() {
+	atomic.AddInt64(&async.pending, -1)
+	atomic.AddInt64(&logger.DroppedLines, 1)
+}
+
+// run performs the queued writes until the writer is stopped.
+func (async *asyncWriter) run() {
+	for {
+		select {
+		case write := <-async.queue:
+			write()
+			atomic.AddInt64(&async.pending, -1)
+
+		case <-async.done:
+			return
+		}
+	}
+}
+
+// drain waits for the pending writes to complete or the timeout to expire.
+func (async *asyncWriter) drain(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		pending := atomic.LoadInt64(&async.pending)
+		if pending <= 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("drain timed out after %v with %d lines queued", timeout, pending)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// stop ends the background goroutine. Lines still queued are discarded.
+func (async *asyncWriter) stop() {
+	close(async.done)
+}
@@ -0,0 +1,175 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditTrail is the file the audit records are appended to. It is separate from
+// the diagnostic logs, so Start, Stop and the logging levels don't affect it.
+type auditTrail struct {
+	mutex      sync.Mutex
+	path       string
+	daysToKeep int
+	day        string
+	file       *os.File
+}
+
+// audit maintains the audit trail configured by ConfigureAudit.
+var audit auditTrail
+
+// ConfigureAudit appends the audit records to a file in a date directory below
+// path, one file per day. Date directories older than daysToKeep are removed
+// when a new day's file is opened, a daysToKeep of 0 or less keeps every
+// directory. Until it's called the audit records are written to stdout.
+func ConfigureAudit(path string, daysToKeep int) error {
+	path = strings.TrimRight(path, "/")
+
+	audit.mutex.Lock()
+	previous := audit.file
+	audit.path = path
+	audit.daysToKeep = daysToKeep
+	audit.day = ""
+	audit.file = nil
+	err := audit.open(time.Now())
+	audit.mutex.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logger.LogDirectoryCleanup(path, daysToKeep)
+	return nil
+}
+
+// CloseAudit closes the audit file. Records written afterwards go to stdout
+// until ConfigureAudit is called again.
+func CloseAudit() error {
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+
+	if audit.file == nil {
+		return nil
+	}
+
+	err := audit.file.Close()
+	audit.file = nil
+	audit.path = ""
+	return err
+}
+
+// Audit records who did what to which target. Audit records are always JSON,
+// are written whatever the logging level is and never go to the diagnostic
+// destinations or the sinks. Fields are redacted like any other line.
+//
+//	log.Audit("alice", "delete", "invoice/42", log.Field{Key: "reason", Value: "duplicate"})
+//
+//	{"time":"...","actor":"alice","action":"delete","target":"invoice/42","caller":"main.go:12","reason":"duplicate"}
+func Audit(actor string, action string, target string, fields ...Field) {
+	rec := Record{Level: LEVEL_INFO, Time: time.Now(), Tag: "Audit", Caller: "???:0", Fields: fields}
+	if info, ok := lookupCaller(1); ok {
+		rec.Caller = info.caller
+	}
+
+	logger.sanitizeKeys(&rec)
+
+	logger.Serialize.Lock()
+	keys, patterns := logger.RedactKeys, logger.RedactPatterns
+	ending := logger.LineEnding
+	logger.Serialize.Unlock()
+
+	rec = redactRecord(rec, keys, patterns)
+	line := applyLineEnding(encodeAudit(actor, action, target, rec), ending)
+
+	if err := audit.write(line, rec.Time); err != nil {
+		internalErrorf(err, "Audit", "Actor[%s] Action[%s] Target[%s]", actor, action, target)
+	}
+}
+
+// write appends the line to today's audit file, opening a new file when the
+// day has changed.
+func (audit *auditTrail) write(line []byte, now time.Time) error {
+	audit.mutex.Lock()
+
+	if audit.path == "" {
+		audit.mutex.Unlock()
+		_, err := os.Stdout.Write(line)
+		return err
+	}
+
+	newDay := now.In(fileLocation).Format("2006-01-02") != audit.day
+	if newDay {
+		if err := audit.open(now); err != nil {
+			audit.mutex.Unlock()
+			return err
+		}
+	}
+
+	_, err := audit.file.Write(line)
+	path, daysToKeep := audit.path, audit.daysToKeep
+	audit.mutex.Unlock()
+
+	// The cleanup writes to the diagnostic log, so run it without the lock.
+	if newDay {
+		logger.LogDirectoryCleanup(path, daysToKeep)
+	}
+
+	return err
+}
+
+// open opens the audit file for the day of now, appending to it when it
+// already exists. The mutex must be held by the caller.
+func (audit *auditTrail) open(now time.Time) error {
+	day := now.In(fileLocation).Format("2006-01-02")
+	filePath := fmt.Sprintf("%s/%s/", audit.path, day)
+
+	if err := os.MkdirAll(filePath, os.ModePerm); err != nil {
+		return fmt.Errorf("Failed to Create audit directory : %s : %s", filePath, err)
+	}
+
+	name := fmt.Sprintf("%s%s.audit.json", filePath, day)
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return fmt.Errorf("Failed to Open audit file : %s : %s", name, err)
+	}
+
+	if audit.file != nil {
+		audit.file.Close()
+	}
+
+	audit.file = file
+	audit.day = day
+	return nil
+}
+
+// encodeAudit writes the audit record as a JSON object. The time, actor, action,
+// target and caller keys come first followed by the fields.
+func encodeAudit(actor string, action string, target string, rec Record) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	writeJSONField(&buf, "time", rec.Time.Format(time.RFC3339Nano), true)
+	writeJSONField(&buf, "actor", actor, false)
+	writeJSONField(&buf, "action", action, false)
+	writeJSONField(&buf, "target", target, false)
+	writeJSONField(&buf, "caller", rec.Caller, false)
+
+	for _, field := range rec.Fields {
+		writeJSONField(&buf, field.Key, field.Value, false)
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,81 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// Banner writes a startup record with the fields, such as the version and the
+// commit, to the Info destination. The Go version is added as go_version. The
+// text format draws the fields in a box, the other formats write them as the
+// fields of a single record.
+//
+//	log.Banner(map[string]string{"service": "orders", "version": "1.4.2"})
+func Banner(fields map[string]string) {
+	if !logger.enabled(LEVEL_INFO) {
+		return
+	}
+
+	values := make(map[string]string, len(fields)+1)
+	values["go_version"] = runtime.Version()
+	for key, value := range fields {
+		values[key] = value
+	}
+
+	rec := Record{Level: LEVEL_INFO, Title: "main", Function: "Banner", Tag: "Info", Fields: mapFields(reflect.ValueOf(values))}
+
+	// The box is only drawn when every destination writes text.
+	logger.Serialize.Lock()
+	_, consoleText := logger.consoleEncoder().(TextEncoder)
+	_, fileText := logger.fileEncoder().(TextEncoder)
+	logger.Serialize.Unlock()
+
+	if consoleText && fileText {
+		rec.Message = bannerBox(rec.Fields)
+		rec.Fields = nil
+	}
+
+	logger.output(2, rec)
+}
+
+// bannerBox draws the fields in a box, one field per line.
+//
+//	+------------------------+
+//	| go_version : go1.21.0  |
+//	| version    : 1.4.2     |
+//	+------------------------+
+func bannerBox(fields []Field) string {
+	keyWidth := 0
+	for _, field := range fields {
+		if n := utf8.RuneCountInString(field.Key); n > keyWidth {
+			keyWidth = n
+		}
+	}
+
+	lines := make([]string, len(fields))
+	width := 0
+	for i, field := range fields {
+		lines[i] = fmt.Sprintf("%-*s : %v", keyWidth, field.Key, field.Value)
+		if n := utf8.RuneCountInString(lines[i]); n > width {
+			width = n
+		}
+	}
+
+	border := "+" + strings.Repeat("-", width+2) + "+"
+
+	var box strings.Builder
+	box.WriteString("\n" + border + "\n")
+	for _, line := range lines {
+		fmt.Fprintf(&box, "| %s%s |\n", line, strings.Repeat(" ", width-utf8.RuneCountInString(line)))
+	}
+	box.WriteString(border)
+
+	return box.String()
+}
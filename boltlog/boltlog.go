@@ -0,0 +1,385 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+/*
+Package boltlog stores log records in a bbolt file so the recent logs can be
+searched on a device that has no log shipper. The store is a sink, records
+are indexed by time, level and title and records older than the maximum age
+are pruned as new ones are written.
+
+	store, err := boltlog.Open("/var/lib/app/logs.db", 7*24*time.Hour)
+	if err != nil {
+	    return err
+	}
+	log.AddSink(log.LEVEL_INFO, store)
+
+	records, err := store.Query(boltlog.Filter{Level: log.LEVEL_WARN, Since: time.Now().Add(-time.Hour)})
+*/
+package boltlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/finapps/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// pruneInterval is how often the records older than the maximum age are removed.
+const pruneInterval = time.Minute
+
+var (
+	recordsBucket = []byte("records")
+	levelBucket   = []byte("level")
+	titleBucket   = []byte("title")
+)
+
+// levels are the logging levels in order of severity.
+var levels = []int32{log.LEVEL_TRACE, log.LEVEL_INFO, log.LEVEL_WARN, log.LEVEL_ERROR}
+
+// Store writes records to a bbolt file and answers queries over them.
+type Store struct {
+	db        *bolt.DB
+	maxAge    time.Duration
+	mutex     sync.Mutex
+	lastPrune time.Time
+}
+
+// Open opens or creates the store at path. Records older than maxAge are
+// removed, a maxAge of 0 or less keeps every record.
+func Open(path string, maxAge time.Duration) (*Store, error) {
+	db, err := bolt.Open(path, 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, levelBucket, titleBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := Store{db: db, maxAge: maxAge}
+	if err := store.Prune(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &store, nil
+}
+
+// WriteRecord implements the log.Sink interface.
+func (store *Store) WriteRecord(rec log.Record) error {
+	value, err := json.Marshal(newStoredRecord(rec))
+	if err != nil {
+		return err
+	}
+
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+
+		sequence, err := records.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		key := recordKey(rec.Time, sequence)
+		if err := records.Put(key, value); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(levelBucket).Put(levelKey(rec.Level, key), nil); err != nil {
+			return err
+		}
+
+		return tx.Bucket(titleBucket).Put(titleKey(rec.Title, key), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	store.mutex.Lock()
+	prune := store.maxAge > 0 && time.Since(store.lastPrune) >= pruneInterval
+	store.mutex.Unlock()
+
+	if prune {
+		return store.Prune()
+	}
+
+	return nil
+}
+
+// Close implements the io.Closer interface so Stop closes the store.
+func (store *Store) Close() error {
+	return store.db.Close()
+}
+
+// Prune removes the records older than the maximum age and their index entries.
+func (store *Store) Prune() error {
+	if store.maxAge <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	cutoff := recordKey(now.Add(-store.maxAge), 0)
+
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+
+		var old [][]byte
+		cursor := records.Cursor()
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, cutoff) < 0; key, _ = cursor.Next() {
+			old = append(old, append([]byte(nil), key...))
+		}
+
+		for _, key := range old {
+			var stored storedRecord
+			if err := json.Unmarshal(records.Get(key), &stored); err == nil {
+				tx.Bucket(levelBucket).Delete(levelKey(stored.Level, key))
+				tx.Bucket(titleBucket).Delete(titleKey(stored.Title, key))
+			}
+
+			if err := records.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	store.mutex.Lock()
+	store.lastPrune = now
+	store.mutex.Unlock()
+
+	return err
+}
+
+//** QUERY
+
+// Filter selects the records returned by Query. The zero value matches every
+// record.
+type Filter struct {
+	Level int32     // The level and every level more severe than it, 0 for all
+	Since time.Time // Records at or after the time, zero for no lower bound
+	Until time.Time // Records before the time, zero for no upper bound
+	Title string    // Records with the title, empty for every title
+	Limit int       // The most recent records up to the limit, 0 for no limit
+}
+
+// Query returns the records matching the filter, oldest first.
+func (store *Store) Query(filter Filter) ([]log.Record, error) {
+	from := recordKey(filter.Since, 0)
+	to := []byte(nil)
+	if !filter.Until.IsZero() {
+		to = recordKey(filter.Until, 0)
+	}
+
+	var recs []log.Record
+	err := store.db.View(func(tx *bolt.Tx) error {
+		keys := store.matchingKeys(tx, filter, from, to)
+
+		if filter.Limit > 0 && len(keys) > filter.Limit {
+			keys = keys[len(keys)-filter.Limit:]
+		}
+
+		records := tx.Bucket(recordsBucket)
+		for _, key := range keys {
+			value := records.Get(key)
+			if value == nil {
+				continue
+			}
+
+			var stored storedRecord
+			if err := json.Unmarshal(value, &stored); err != nil {
+				return err
+			}
+
+			recs = append(recs, stored.record())
+		}
+
+		return nil
+	})
+
+	return recs, err
+}
+
+// matchingKeys returns the sorted keys of the records matching the filter, using
+// the title index when a title is given and the level index otherwise.
+func (store *Store) matchingKeys(tx *bolt.Tx, filter Filter, from []byte, to []byte) [][]byte {
+	var keys [][]byte
+
+	inRange := func(key []byte) bool {
+		return bytes.Compare(key, from) >= 0 && (to == nil || bytes.Compare(key, to) < 0)
+	}
+
+	if filter.Title != "" {
+		prefix := titleKey(filter.Title, nil)
+		levelIndex := tx.Bucket(levelBucket)
+
+		cursor := tx.Bucket(titleBucket).Cursor()
+		for key, _ := cursor.Seek(append(prefix, from...)); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			recKey := key[len(prefix):]
+			if !inRange(recKey) {
+				break
+			}
+
+			if filter.Level == 0 || store.levelMatches(levelIndex, filter.Level, recKey) {
+				keys = append(keys, append([]byte(nil), recKey...))
+			}
+		}
+
+		return keys
+	}
+
+	cursor := tx.Bucket(levelBucket).Cursor()
+	for _, level := range levels {
+		if filter.Level != 0 && level < filter.Level {
+			continue
+		}
+
+		prefix := levelKey(level, nil)
+		for key, _ := cursor.Seek(append(prefix, from...)); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			recKey := key[len(prefix):]
+			if !inRange(recKey) {
+				break
+			}
+
+			keys = append(keys, append([]byte(nil), recKey...))
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+
+	return keys
+}
+
+// levelMatches reports if the record has the level or a more severe one.
+func (store *Store) levelMatches(levelIndex *bolt.Bucket, minimum int32, recKey []byte) bool {
+	for _, level := range levels {
+		if level >= minimum && levelIndex.Get(levelKey(level, recKey)) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+//** KEYS
+
+// recordKey orders the records by time. The sequence keeps records written in
+// the same nanosecond apart.
+func recordKey(t time.Time, sequence uint64) []byte {
+	key := make([]byte, 16)
+
+	var nanos int64
+	if !t.IsZero() {
+		nanos = t.UnixNano()
+	}
+
+	// Flip the sign bit so negative times sort before positive ones.
+	binary.BigEndian.PutUint64(key, uint64(nanos)^(1<<63))
+	binary.BigEndian.PutUint64(key[8:], sequence)
+	return key
+}
+
+// levelKey returns the level index key for the record key.
+func levelKey(level int32, key []byte) []byte {
+	return append([]byte{byte(level)}, key...)
+}
+
+// titleKey returns the title index key for the record key. The title is ended
+// by a zero byte so one title is never the prefix of another.
+func titleKey(title string, key []byte) []byte {
+	index := make([]byte, 0, len(title)+1+len(key))
+	index = append(index, title...)
+	index = append(index, 0)
+	return append(index, key...)
+}
+
+//** STORED RECORD
+
+// storedRecord is the JSON form of a record in the store.
+type storedRecord struct {
+	Level    int32         `json:"level"`
+	Time     time.Time     `json:"time"`
+	Title    string        `json:"title"`
+	Function string        `json:"function"`
+	Tag      string        `json:"tag"`
+	Message  string        `json:"message"`
+	Err      string        `json:"error,omitempty"`
+	Caller   string        `json:"caller"`
+	Fields   []storedField `json:"fields,omitempty"`
+}
+
+// storedField keeps the fields in order, a JSON object would not.
+type storedField struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// newStoredRecord converts the record to its stored form.
+func newStoredRecord(rec log.Record) storedRecord {
+	stored := storedRecord{
+		Level:    rec.Level,
+		Time:     rec.Time,
+		Title:    rec.Title,
+		Function: rec.Function,
+		Tag:      rec.Tag,
+		Message:  rec.Message,
+		Caller:   rec.Caller,
+	}
+
+	if rec.Err != nil {
+		stored.Err = rec.Err.Error()
+	}
+
+	for _, field := range rec.Fields {
+		value := field.Value
+		if _, err := json.Marshal(value); err != nil {
+			value = err.Error()
+		}
+		stored.Fields = append(stored.Fields, storedField{Key: field.Key, Value: value})
+	}
+
+	return stored
+}
+
+// record converts the stored form back to a record. The error only keeps its
+// message and field values are decoded as JSON values.
+func (stored storedRecord) record() log.Record {
+	rec := log.Record{
+		Level:    stored.Level,
+		Time:     stored.Time,
+		Title:    stored.Title,
+		Function: stored.Function,
+		Tag:      stored.Tag,
+		Message:  stored.Message,
+		Caller:   stored.Caller,
+	}
+
+	if stored.Err != "" {
+		rec.Err = errors.New(stored.Err)
+	}
+
+	for _, field := range stored.Fields {
+		rec.Fields = append(rec.Fields, log.Field{Key: field.Key, Value: field.Value})
+	}
+
+	return rec
+}
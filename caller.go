@@ -0,0 +1,65 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// callerInfo is the resolved location of a log call.
+type callerInfo struct {
+	caller   string // file.go:12
+	location string // /full/path/file.go:12
+	function string // package.Function
+}
+
+// callerCache holds the resolved location of each log call site keyed by its
+// program counter. A call site always resolves to the same location, so the
+// file, line and function name are only looked up the first time it logs.
+var callerCache sync.Map
+
+// lookupCaller returns the location of the function calldepth frames up the
+// stack, with the same meaning as the skip of runtime.Caller.
+func lookupCaller(calldepth int) (*callerInfo, bool) {
+	var pcs [1]uintptr
+	if runtime.Callers(calldepth+2, pcs[:]) == 0 {
+		return nil, false
+	}
+
+	if info, ok := callerCache.Load(pcs[0]); ok {
+		return info.(*callerInfo), true
+	}
+
+	info, ok := resolveCaller(pcs[0])
+	if !ok {
+		return nil, false
+	}
+
+	callerCache.Store(pcs[0], info)
+	return info, true
+}
+
+// resolveCaller looks up the file, line and function name of the program
+// counter returned by runtime.Callers.
+func resolveCaller(pc uintptr) (*callerInfo, bool) {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.File == "" {
+		return nil, false
+	}
+
+	return frameCaller(frame), true
+}
+
+// frameCaller returns the location of the stack frame.
+func frameCaller(frame runtime.Frame) *callerInfo {
+	return &callerInfo{
+		caller:   fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line),
+		location: fmt.Sprintf("%s:%d", frame.File, frame.Line),
+		function: funcName(frame.Function),
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestLookupCaller checks that the cached location is the one resolved the
+// first time.
+func TestLookupCaller(t *testing.T) {
+	var infos []*callerInfo
+	for i := 0; i < 2; i++ {
+		info, ok := lookupCaller(0)
+		if !ok {
+			t.Fatal("lookupCaller found no caller")
+		}
+		infos = append(infos, info)
+	}
+
+	if infos[0] != infos[1] {
+		t.Error("the second lookup wasn't served from the cache")
+	}
+
+	if !strings.HasPrefix(infos[0].caller, "caller_test.go:") || infos[0].function != "log.TestLookupCaller" {
+		t.Errorf("lookupCaller = %s %s, want caller_test.go and log.TestLookupCaller", infos[0].caller, infos[0].function)
+	}
+}
+
+// BenchmarkLookupCaller resolves the caller through the cache, the way every
+// log call does.
+func BenchmarkLookupCaller(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		lookupCaller(0)
+	}
+}
+
+// BenchmarkLookupCallerUncached resolves the caller from its program counter
+// on every call, the cost the cache saves.
+func BenchmarkLookupCallerUncached(b *testing.B) {
+	var pcs [1]uintptr
+	for i := 0; i < b.N; i++ {
+		runtime.Callers(2, pcs[:])
+		resolveCaller(pcs[0])
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CaptureLogger collects records in memory instead of writing them. Libraries
+// can log through a CaptureLogger and let the application decide what to do
+// with the records, such as inspecting them or replaying them into tracelog.
+type CaptureLogger struct {
+	mutex   sync.Mutex
+	records []Record
+}
+
+// Capture returns a new CaptureLogger.
+func Capture() *CaptureLogger {
+	return &CaptureLogger{}
+}
+
+// Records returns a copy of the records captured so far.
+func (captureLogger *CaptureLogger) Records() []Record {
+	captureLogger.mutex.Lock()
+	defer captureLogger.mutex.Unlock()
+
+	records := make([]Record, len(captureLogger.records))
+	copy(records, captureLogger.records)
+	return records
+}
+
+// Replay writes the captured records to the tracelog destinations, keeping their
+// original time and caller. The records go through the same steps as a log
+// call, such as the filters, the counts and the email alerts on level. The
+// records are removed from the CaptureLogger.
+func (captureLogger *CaptureLogger) Replay() {
+	captureLogger.mutex.Lock()
+	records := captureLogger.records
+	captureLogger.records = nil
+	captureLogger.mutex.Unlock()
+
+	for _, rec := range records {
+		if rec.caller == nil {
+			rec.caller = &callerInfo{caller: rec.Caller}
+		}
+
+		logger.output(2, rec)
+	}
+}
+
+// WithCapture runs fn with the destinations replaced by a buffer and returns the
+// lines written while it ran. The file, the sinks and the console are left out
+// and the previous configuration is restored before WithCapture returns, which
+// makes it handy for assertions in tests.
+//
+//	lines := log.WithCapture(func() {
+//	    processOrder(order)
+//	})
+func WithCapture(fn func()) []string {
+	config := Snapshot()
+
+	var buf bytes.Buffer
+
+	logger.Serialize.Lock()
+	logger.FileHandle = nil
+	logger.Sinks = nil
+	logger.Ring = nil
+	atomic.StoreInt32(&logger.SinkLevel, 0)
+	logger.Trace, logger.Info, logger.Warning, logger.Error = levelHandles(logger.ConsoleLevel|logger.FileLevel, &buf, &buf)
+	logger.TraceFile, logger.InfoFile, logger.WarningFile, logger.ErrorFile = levelHandles(0, nil, nil)
+	logger.Serialize.Unlock()
+
+	fn()
+
+	// Lines queued by async logging must reach the buffer before it's read.
+	Drain(defaultDrainTimeout)
+	Restore(config)
+
+	text := strings.TrimRight(buf.String(), "\r\n")
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+}
+
+// capture completes the record with the time and caller and stores it.
+func (captureLogger *CaptureLogger) capture(calldepth int, rec Record) {
+	rec.Time = time.Now()
+	rec.Caller = "???:0"
+	if info, ok := lookupCaller(calldepth); ok {
+		rec.Caller = info.caller
+		rec.caller = info
+	}
+
+	captureLogger.mutex.Lock()
+	captureLogger.records = append(captureLogger.records, rec)
+	captureLogger.mutex.Unlock()
+}
+
+//** STARTED AND COMPLETED
+
+// Started captures a Trace record with a Started tag
+func (captureLogger *CaptureLogger) Started(title string, functionName string) {
+	captureLogger.capture(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Started"})
+}
+
+// Startedf captures a Trace record with a Started tag
+func (captureLogger *CaptureLogger) Startedf(title string, functionName string, format string, a ...interface{}) {
+	captureLogger.capture(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Started", Message: fmt.Sprintf(format, a...)})
+}
+
+// Completed captures a Trace record with a Completed tag
+func (captureLogger *CaptureLogger) Completed(title string, functionName string) {
+	captureLogger.capture(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Completed"})
+}
+
+// Completedf captures a Trace record with a Completed tag
+func (captureLogger *CaptureLogger) Completedf(title string, functionName string, format string, a ...interface{}) {
+	captureLogger.capture(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Completed", Message: fmt.Sprintf(format, a...)})
+}
+
+// CompletedError captures an Error record with a Completed tag
+func (captureLogger *CaptureLogger) CompletedError(err error, title string, functionName string) {
+	captureLogger.capture(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ERROR", Err: err})
+}
+
+// CompletedErrorf captures an Error record with a Completed tag
+func (captureLogger *CaptureLogger) CompletedErrorf(err error, title string, functionName string, format string, a ...interface{}) {
+	captureLogger.capture(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ERROR", Message: fmt.Sprintf(format, a...), Err: err})
+}
+
+//** TRACE
+
+// Trace captures a Trace record
+func (captureLogger *CaptureLogger) Trace(title string, functionName string, format string, a ...interface{}) {
+	captureLogger.capture(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...)})
+}
+
+//** INFO
+
+// Info captures an Info record
+func (captureLogger *CaptureLogger) Info(title string, functionName string, format string, a ...interface{}) {
+	captureLogger.capture(2, Record{Level: LEVEL_INFO, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...)})
+}
+
+//** WARNING
+
+// Warning captures a Warning record
+func (captureLogger *CaptureLogger) Warning(title string, functionName string, format string, a ...interface{}) {
+	captureLogger.capture(2, Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...)})
+}
+
+//** ERROR
+
+// Error captures an Error record and accepts an err
+func (captureLogger *CaptureLogger) Error(err error, title string, functionName string) {
+	captureLogger.capture(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Err: err})
+}
+
+// Errorf captures an Error record and accepts an err
+func (captureLogger *CaptureLogger) Errorf(err error, title string, functionName string, format string, a ...interface{}) {
+	captureLogger.capture(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Message: fmt.Sprintf(format, a...), Err: err})
+}
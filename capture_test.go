@@ -0,0 +1,46 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestReplayFilter checks that the filters registered by AddFilter see the
+// replayed records, and that the records keep the caller of the capture.
+func TestReplayFilter(t *testing.T) {
+	writer := &lineWriter{}
+	turnOnLogging(0, LEVEL_TRACE, writer)
+	defer turnOnLogging(0, 0, nil)
+
+	AddFilter(func(rec *Record) bool {
+		if strings.Contains(rec.Message, "drop") {
+			return false
+		}
+
+		rec.Message = strings.Replace(rec.Message, "card", "****", -1)
+		return true
+	})
+	defer ClearFilters()
+
+	capture := Capture()
+	capture.Info("main", "TestReplayFilter", "drop this line")
+	capture.Info("main", "TestReplayFilter", "Payment[card]")
+	capture.Replay()
+
+	if len(writer.lines) != 1 {
+		t.Fatalf("replayed %d lines, want 1 : %q", len(writer.lines), writer.lines)
+	}
+
+	line := writer.lines[0]
+	if !strings.Contains(line, "Payment[****]") {
+		t.Errorf("replayed %q, want the message changed by the filter", line)
+	}
+
+	if !strings.Contains(line, "capture_test.go:") {
+		t.Errorf("replayed %q, want the caller of the capture", line)
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import "time"
+
+// gzipFlushInterval is how often a compressed log file is flushed so the lines
+// written so far can be read with zcat.
+const gzipFlushInterval = time.Second
+
+// StartFileGzip initializes tracelog like StartFileSplit but compresses the log
+// file as it's written, adding .gz to the extension. The compressed stream is
+// flushed every second, after every error line and when the file is rotated,
+// so the file can be read while it's open, and it's completed by Stop.
+// SetFlushInterval changes how often it's flushed. Files set up with
+// SetLevelFileExtension are not compressed.
+func StartFileGzip(consoleLevel int32, fileLevel int32, baseFilePath string, daysToKeep int) {
+	startFile(consoleLevel, fileLevel, baseFilePath, daysToKeep, fileOptions{compress: true})
+}
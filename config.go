@@ -0,0 +1,356 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// Config contains the configuration of the logging system. Snapshot captures the
+// current configuration and Restore puts it back, along with the sinks, the
+// event writer and the file in use when the snapshot was taken.
+//
+//	defer log.Restore(log.Snapshot())
+type Config struct {
+	ConsoleLevel    int32
+	FileLevel       int32
+	BaseFilePath    string
+	DaysToKeep      int
+	Encoder         Encoder
+	ConsoleEncoder  Encoder
+	FileEncoder     Encoder
+	LineEnding      string
+	Tags            map[string]string
+	Prefixes        map[int32]string
+	MaxLineLength   int
+	IncludeFuncName bool
+
+	// The email server used for alerts, email is off when EmailHost is empty.
+	EmailHost     string
+	EmailPort     int
+	EmailUserName string
+	EmailPassword string
+	EmailTo       []string
+
+	// Every other setting in use when the snapshot was taken.
+	settings settings
+
+	// The destinations in use when the snapshot was taken.
+	destinations configDestinations
+}
+
+// configDestinations are the destinations of a snapshot. The starts and stops
+// tell Restore if the file and the sinks have been closed since.
+type configDestinations struct {
+	starts      int64
+	stops       int64
+	fileHandle  io.Writer
+	logFile     *os.File
+	sinks       []sinkEntry
+	ring        *ringBuffer
+	eventWriter io.Writer
+}
+
+// Snapshot returns the current configuration of the logging system.
+func Snapshot() Config {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	config := Config{
+		ConsoleLevel:    logger.ConsoleLevel,
+		FileLevel:       logger.FileLevel,
+		BaseFilePath:    logger.BaseFilePath,
+		DaysToKeep:      logger.DaysToKeep,
+		Encoder:         logger.Encoder,
+		ConsoleEncoder:  logger.ConsoleEncoder,
+		FileEncoder:     logger.FileEncoder,
+		LineEnding:      logger.LineEnding,
+		Tags:            logger.Tags,
+		MaxLineLength:   logger.MaxLineLength,
+		IncludeFuncName: logger.IncludeFuncName,
+		settings:        logger.settings,
+		destinations: configDestinations{
+			starts:      atomic.LoadInt64(&starts),
+			stops:       atomic.LoadInt64(&stops),
+			fileHandle:  logger.FileHandle,
+			logFile:     logger.LogFile,
+			sinks:       append([]sinkEntry(nil), logger.Sinks...),
+			ring:        logger.Ring,
+			eventWriter: logger.EventWriter,
+		},
+	}
+
+	// These are changed without the lock.
+	config.settings.NilErrorPolicy = atomic.LoadInt32(&logger.NilErrorPolicy)
+	config.settings.WriteTimeout = atomic.LoadInt64(&logger.WriteTimeout)
+	config.settings.OverflowPolicy = atomic.LoadInt32(&logger.OverflowPolicy)
+	config.settings.EmailOnLevel = atomic.LoadInt32(&logger.EmailOnLevel)
+	config.settings.KeyPolicy = atomic.LoadInt32(&logger.KeyPolicy)
+
+	if email := logger.EmailConfiguration; email != nil {
+		config.EmailHost = email.Host
+		config.EmailPort = email.Port
+		config.EmailUserName = email.UserName
+		config.EmailPassword = email.Password
+		config.EmailTo = email.To
+	}
+
+	if logger.Prefixes != nil {
+		config.Prefixes = make(map[int32]string, len(logger.Prefixes))
+		for level, prefix := range logger.Prefixes {
+			config.Prefixes[level] = prefix
+		}
+	}
+
+	return config
+}
+
+// Restore replaces the configuration of the logging system with one returned
+// by Snapshot. The levels, the settings and the destinations are restored, the
+// file settings BaseFilePath and DaysToKeep are kept. The sinks added since the
+// snapshot are closed if they implement io.Closer, and a file started since is
+// closed. A file or sink closed by Stop since the snapshot can't be opened
+// again, it's left out and an error is returned.
+func Restore(config Config) error {
+	logger.Serialize.Lock()
+
+	logger.settings = config.settings
+	atomic.StoreInt32(&logger.NilErrorPolicy, config.settings.NilErrorPolicy)
+	atomic.StoreInt64(&logger.WriteTimeout, config.settings.WriteTimeout)
+	atomic.StoreInt32(&logger.OverflowPolicy, config.settings.OverflowPolicy)
+	atomic.StoreInt32(&logger.EmailOnLevel, config.settings.EmailOnLevel)
+	atomic.StoreInt32(&logger.KeyPolicy, config.settings.KeyPolicy)
+
+	// The exported fields may have been changed since the snapshot.
+	logger.Encoder = config.Encoder
+	logger.ConsoleEncoder = config.ConsoleEncoder
+	logger.FileEncoder = config.FileEncoder
+	logger.LineEnding = config.LineEnding
+	logger.Tags = config.Tags
+	logger.Prefixes = config.Prefixes
+	logger.MaxLineLength = config.MaxLineLength
+	logger.IncludeFuncName = config.IncludeFuncName
+
+	logger.EmailConfiguration = nil
+	if config.EmailHost != "" {
+		logger.EmailConfiguration = newEmailConfiguration(config.EmailHost, config.EmailPort, config.EmailUserName, config.EmailPassword, config.EmailTo)
+	}
+
+	files, sinks, err := logger.restoreDestinations(config.destinations)
+	logger.setHandles(config.ConsoleLevel, config.FileLevel)
+	logger.Serialize.Unlock()
+
+	for _, entry := range sinks {
+		if closer, ok := entry.sink.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+
+	files.close()
+	return err
+}
+
+// restoreDestinations puts back the destinations of a snapshot. It returns the
+// files and the sinks opened since the snapshot, which must be closed once the
+// Serialize lock is released. The handles must be rebuilt by the caller. The
+// Serialize lock must be held by the caller.
+func (traceLog *traceLog) restoreDestinations(destinations configDestinations) (detachedFiles, []sinkEntry, error) {
+	var err error
+
+	// Without a start or stop since the snapshot the file in use is the one
+	// of the snapshot, rotated or not, so only the handle is put back.
+	var files detachedFiles
+	switch {
+	case atomic.LoadInt64(&starts) == destinations.starts:
+		traceLog.FileHandle = destinations.fileHandle
+		if traceLog.LogFile != nil {
+			traceLog.FileHandle = traceLog.fileBase()
+			if traceLog.FileBuffer != nil {
+				traceLog.FileHandle = traceLog.FileBuffer
+			}
+		}
+
+	default:
+		if traceLog.LogFile != nil {
+			files, _ = traceLog.detachFile()
+		}
+
+		// A writer passed to the start is never closed by the logging system.
+		traceLog.FileHandle = nil
+		if destinations.logFile == nil {
+			traceLog.FileHandle = destinations.fileHandle
+		} else {
+			err = errors.New("the log file of the snapshot has been closed, start it again with StartFile")
+		}
+	}
+
+	snapshot := make(map[*int32]bool, len(destinations.sinks))
+	for _, entry := range destinations.sinks {
+		snapshot[entry.busy] = true
+	}
+
+	var added []sinkEntry
+	for _, entry := range traceLog.Sinks {
+		if !snapshot[entry.busy] {
+			added = append(added, entry)
+		}
+	}
+
+	// Stop closed the sinks that implement io.Closer.
+	stopped := atomic.LoadInt64(&stops) != destinations.stops
+
+	var sinks []sinkEntry
+	var sinkLevel int32
+	for _, entry := range destinations.sinks {
+		if _, ok := entry.sink.(io.Closer); ok && stopped {
+			if err == nil {
+				err = errors.New("the sinks of the snapshot have been closed by Stop, add them again with AddSink")
+			}
+			continue
+		}
+
+		sinks = append(sinks, entry)
+		sinkLevel |= entry.logLevel
+	}
+
+	traceLog.Sinks = sinks
+	traceLog.Ring = destinations.ring
+	atomic.StoreInt32(&traceLog.SinkLevel, sinkLevel)
+	traceLog.EventWriter = destinations.eventWriter
+
+	return files, added, err
+}
+
+// StartWithConfig validates the configuration and initializes tracelog with
+// it. A file is created when BaseFilePath is set. Nothing is started when the
+// configuration is not valid.
+func StartWithConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if config.BaseFilePath != "" {
+		StartFileSplit(config.ConsoleLevel, config.FileLevel, config.BaseFilePath, config.DaysToKeep)
+	} else {
+		turnOnLogging(config.ConsoleLevel, 0, nil)
+	}
+
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	if config.Encoder != nil {
+		logger.Encoder = config.Encoder
+	}
+	if config.ConsoleEncoder != nil || config.FileEncoder != nil {
+		logger.ConsoleEncoder = config.ConsoleEncoder
+		logger.FileEncoder = config.FileEncoder
+		logger.setHandles(logger.ConsoleLevel, logger.FileLevel)
+	}
+	if config.LineEnding != "" {
+		logger.LineEnding = config.LineEnding
+	}
+	if config.Tags != nil {
+		logger.Tags = config.Tags
+	}
+	if config.Prefixes != nil {
+		logger.Prefixes = config.Prefixes
+	}
+	logger.MaxLineLength = config.MaxLineLength
+	logger.IncludeFuncName = config.IncludeFuncName
+
+	if config.EmailHost != "" {
+		logger.EmailConfiguration = newEmailConfiguration(config.EmailHost, config.EmailPort, config.EmailUserName, config.EmailPassword, config.EmailTo)
+	}
+
+	return nil
+}
+
+// Validate checks the configuration without starting the logging system, so
+// a bad configuration can be reported while the program is starting.
+func (config Config) Validate() error {
+	if !validLevelMask(config.ConsoleLevel) {
+		return fmt.Errorf("ConsoleLevel[%d] is not a valid logging level", config.ConsoleLevel)
+	}
+
+	if !validLevelMask(config.FileLevel) {
+		return fmt.Errorf("FileLevel[%d] is not a valid logging level", config.FileLevel)
+	}
+
+	if config.BaseFilePath == "" {
+		if config.FileLevel != 0 {
+			return errors.New("FileLevel is set without a BaseFilePath")
+		}
+	} else if err := checkDirWritable(config.BaseFilePath); err != nil {
+		return err
+	}
+
+	if config.DaysToKeep < 0 {
+		return fmt.Errorf("DaysToKeep[%d] is negative, use 0 to keep every directory", config.DaysToKeep)
+	}
+
+	if config.MaxLineLength < 0 {
+		return fmt.Errorf("MaxLineLength[%d] is negative, use 0 for no limit", config.MaxLineLength)
+	}
+
+	if config.EmailHost != "" || len(config.EmailTo) > 0 {
+		if config.EmailHost == "" {
+			return errors.New("EmailTo is set without an EmailHost")
+		}
+
+		if config.EmailPort <= 0 || config.EmailPort > 65535 {
+			return fmt.Errorf("EmailPort[%d] is not a valid port", config.EmailPort)
+		}
+
+		if len(config.EmailTo) == 0 {
+			return errors.New("EmailHost is set without any EmailTo addresses")
+		}
+	}
+
+	return nil
+}
+
+// validLevelMask reports if the mask only contains the known logging levels.
+func validLevelMask(mask int32) bool {
+	return mask&^(LEVEL_TRACE|LEVEL_INFO|LEVEL_WARN|LEVEL_ERROR) == 0
+}
+
+// checkDirWritable checks that the log files can be created below the path.
+// The directories that do not exist yet are created by Start, so the nearest
+// directory that exists must be writable.
+func checkDirWritable(path string) error {
+	dir := filepath.Clean(path)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("BaseFilePath[%s] : %s is not a directory", path, dir)
+			}
+			break
+		}
+
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("BaseFilePath[%s] : %s", path, err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("BaseFilePath[%s] has no existing parent directory", path)
+		}
+		dir = parent
+	}
+
+	test, err := os.CreateTemp(dir, ".writable-")
+	if err != nil {
+		return fmt.Errorf("BaseFilePath[%s] is not writable : %s", path, err)
+	}
+
+	test.Close()
+	return os.Remove(test.Name())
+}
@@ -0,0 +1,81 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestRestoreDestinations starts a file and adds a sink and an event writer
+// after a snapshot. Restore must close the file and the sink and put back the
+// destinations of the snapshot.
+func TestRestoreDestinations(t *testing.T) {
+	writer := &lineWriter{}
+	turnOnLogging(0, LEVEL_INFO, writer)
+	defer turnOnLogging(0, 0, nil)
+
+	kept := &closeSink{messages: make(map[string]bool)}
+	AddSink(LEVEL_INFO, kept)
+
+	config := Snapshot()
+
+	StartFile(LEVEL_INFO, t.TempDir(), 0)
+	logf := logger.LogFile
+
+	added := &closeSink{messages: make(map[string]bool)}
+	AddSink(LEVEL_INFO, added)
+	SetEventWriter(&bytes.Buffer{})
+
+	if err := Restore(config); err != nil {
+		t.Fatalf("Restore : %s", err)
+	}
+
+	if logger.LogFile != nil || logger.FileHandle != writer {
+		t.Errorf("Restore left the file %v and the handle %T, want the writer of the snapshot", logger.LogFile, logger.FileHandle)
+	}
+
+	if _, err := logf.Stat(); err == nil {
+		t.Error("the file started after the snapshot is still open")
+	}
+
+	if !added.closed || kept.closed {
+		t.Errorf("closed the added sink %v and the kept sink %v, want only the added one", added.closed, kept.closed)
+	}
+
+	if len(logger.Sinks) != 1 || logger.Sinks[0].sink != kept {
+		t.Errorf("Restore left %d sinks, want the sink of the snapshot", len(logger.Sinks))
+	}
+
+	if logger.EventWriter != nil {
+		t.Error("Restore left the event writer set after the snapshot")
+	}
+
+	Info("main", "TestRestoreDestinations", "After Restore")
+	if len(writer.lines) != 1 || !kept.messages["After Restore"] {
+		t.Errorf("wrote %d lines to the writer and %d to the sink after Restore, want 1 each", len(writer.lines), len(kept.messages))
+	}
+}
+
+// TestRestoreAfterStop checks that Restore reports the sinks closed by Stop
+// since the snapshot rather than writing to them.
+func TestRestoreAfterStop(t *testing.T) {
+	turnOnLogging(0, 0, nil)
+	defer turnOnLogging(0, 0, nil)
+
+	sink := &closeSink{messages: make(map[string]bool)}
+	AddSink(LEVEL_INFO, sink)
+
+	config := Snapshot()
+	Stop()
+
+	if err := Restore(config); err == nil {
+		t.Error("Restore returned no error for a sink closed by Stop")
+	}
+
+	if len(logger.Sinks) != 0 {
+		t.Errorf("Restore put back %d sinks closed by Stop", len(logger.Sinks))
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// startTimeKey is the context key for the time the context scoped work started.
+type startTimeKey struct{}
+
+// WithStartTime returns a context that remembers the current time, so
+// LogContextEnd can report how long the work ran.
+func WithStartTime(ctx context.Context) context.Context {
+	return context.WithValue(ctx, startTimeKey{}, time.Now())
+}
+
+// LogContextEnd writes a line describing why the context ended. A canceled
+// context is written to the Info destination and a deadline or a custom cause
+// to the Warning destination, with the reason and the cause as fields. A
+// context that has not ended is written to the Trace destination. When the
+// context was returned by WithStartTime the elapsed time is added.
+//
+//	defer log.LogContextEnd(ctx, "main", "ProcessOrder")
+func LogContextEnd(ctx context.Context, title string, functionName string) {
+	level := LEVEL_TRACE
+	message := "Context Ended"
+	reason := "active"
+
+	err := ctx.Err()
+	switch {
+	case err == nil:
+		message = "Context Still Active"
+	case errors.Is(err, context.DeadlineExceeded):
+		level = LEVEL_WARN
+		reason = "deadline exceeded"
+	case errors.Is(err, context.Canceled):
+		level = LEVEL_INFO
+		reason = "canceled"
+	}
+
+	fields := []Field{{Key: "reason", Value: reason}}
+
+	if cause := context.Cause(ctx); cause != nil && cause != err {
+		// A cause given to CancelCauseFunc is more than a normal cancel.
+		level = LEVEL_WARN
+		fields = append(fields, Field{Key: "cause", Value: cause.Error()})
+	}
+
+	if start, ok := ctx.Value(startTimeKey{}).(time.Time); ok {
+		fields = append(fields, Duration("elapsed", time.Since(start)))
+	}
+
+	logger.output(2, Record{Level: level, Title: title, Function: functionName, Tag: "Context", Message: message, Fields: fields})
+}
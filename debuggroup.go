@@ -0,0 +1,42 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import "sync"
+
+// debugGroups holds the names of the debug groups that are turned on.
+var debugGroups sync.Map
+
+// EnableDebugGroup turns on the DebugGroup lines of the group, such as "sql".
+func EnableDebugGroup(group string) {
+	debugGroups.Store(group, struct{}{})
+}
+
+// DisableDebugGroup turns off the DebugGroup lines of the group.
+func DisableDebugGroup(group string) {
+	debugGroups.Delete(group)
+}
+
+// DebugGroupEnabled reports if the group has been turned on.
+func DebugGroupEnabled(group string) bool {
+	_, ok := debugGroups.Load(group)
+	return ok
+}
+
+// DebugGroup writes the text returned by fn to the Info destination when the
+// group is turned on, whether or not the Trace level is. The function is only
+// called when the line is written, so a large structure can be dumped without
+// any cost while the group is off. The group is added as a field.
+//
+//	log.DebugGroup("sql", "main", "Query", func() string {
+//	    return fmt.Sprintf("%+v", plan)
+//	})
+func DebugGroup(group string, title string, functionName string, fn func() string) {
+	if !DebugGroupEnabled(group) || !logger.enabled(LEVEL_INFO) {
+		return
+	}
+
+	logger.output(2, Record{Level: LEVEL_INFO, Title: title, Function: functionName, Tag: "Debug", Message: fn(), Fields: []Field{{Key: "group", Value: group}}})
+}
@@ -0,0 +1,155 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// levelPrefixes maps the severity markers libraries put at the start of their
+// lines to the logging level. The markers are matched without regard to case.
+var levelPrefixes = []struct {
+	prefix string
+	level  int32
+}{
+	{"[ERROR]", LEVEL_ERROR},
+	{"[ERR]", LEVEL_ERROR},
+	{"ERROR:", LEVEL_ERROR},
+	{"[WARNING]", LEVEL_WARN},
+	{"[WARN]", LEVEL_WARN},
+	{"WARNING:", LEVEL_WARN},
+	{"WARN:", LEVEL_WARN},
+	{"[INFO]", LEVEL_INFO},
+	{"INFO:", LEVEL_INFO},
+	{"[DEBUG]", LEVEL_TRACE},
+	{"[TRACE]", LEVEL_TRACE},
+	{"DEBUG:", LEVEL_TRACE},
+	{"TRACE:", LEVEL_TRACE},
+}
+
+// stdlogHeader matches the date, time and file the standard library's log
+// package writes before the message.
+var stdlogHeader = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} )?(\d{2}:\d{2}:\d{2}(\.\d{6})? )?(\S+:\d+: )?`)
+
+// writerFunctions is the prefix of the names of the levelDetectingWriter
+// methods, which are skipped when looking for the code that wrote a line.
+var writerFunctions = reflect.TypeOf((*levelDetectingWriter)(nil)).Elem().PkgPath() + ".(*levelDetectingWriter)."
+
+// levelDetectingWriter writes each line to the destination for the severity
+// marker it starts with.
+type levelDetectingWriter struct {
+	mutex        sync.Mutex
+	defaultLevel int32
+	partial      []byte
+}
+
+// LevelDetectingWriter returns a writer for libraries that mark the severity of
+// their own lines, such as "[ERROR] connection lost" or "WARN: retrying". Each
+// line is written to the destination for its marker, with the marker removed,
+// and lines without a marker are written at the default level.
+//
+// The prefix of the standard logger and the date, time and file the standard
+// library's log package writes are removed before the marker is looked for,
+// so the standard logger can write to it with any flags. The caller is the code
+// that called the standard logger.
+//
+//	stdlog.SetOutput(log.LevelDetectingWriter(log.LEVEL_INFO))
+func LevelDetectingWriter(defaultLevel int32) io.Writer {
+	return &levelDetectingWriter{defaultLevel: defaultLevel}
+}
+
+// Write implements the io.Writer interface. A line is written once its newline
+// arrives.
+func (levelDetectingWriter *levelDetectingWriter) Write(p []byte) (int, error) {
+	levelDetectingWriter.mutex.Lock()
+	defer levelDetectingWriter.mutex.Unlock()
+
+	levelDetectingWriter.partial = append(levelDetectingWriter.partial, p...)
+	caller := writerCaller()
+
+	for {
+		i := bytes.IndexByte(levelDetectingWriter.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := string(bytes.TrimRight(levelDetectingWriter.partial[:i], "\r"))
+		levelDetectingWriter.partial = levelDetectingWriter.partial[i+1:]
+
+		if line != "" {
+			level, message := detectLevel(line, levelDetectingWriter.defaultLevel)
+			logger.output(2, Record{Level: level, Title: "main", Function: "LevelDetectingWriter", Tag: detectedTag(level), Message: message, caller: caller})
+		}
+	}
+
+	return len(p), nil
+}
+
+// writerCaller returns the location of the code that wrote to the writer, the
+// first function up the stack outside the writer and the standard library's
+// log and fmt packages.
+func writerCaller() *callerInfo {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+
+	for {
+		frame, more := frames.Next()
+
+		if !strings.HasPrefix(frame.Function, writerFunctions) && !strings.HasPrefix(frame.Function, "log.") && !strings.HasPrefix(frame.Function, "fmt.") {
+			if frame.File == "" {
+				return nil
+			}
+
+			return frameCaller(frame)
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+// detectLevel returns the level for the marker at the start of the line and the
+// line without the marker. The prefix of the standard logger and the header
+// the standard library's log package writes are removed first.
+func detectLevel(line string, defaultLevel int32) (int32, string) {
+	line = stripStdlogHeader(line)
+	trimmed := strings.TrimLeft(line, " \t")
+
+	for _, entry := range levelPrefixes {
+		if len(trimmed) >= len(entry.prefix) && strings.EqualFold(trimmed[:len(entry.prefix)], entry.prefix) {
+			return entry.level, strings.TrimLeft(trimmed[len(entry.prefix):], " \t")
+		}
+	}
+
+	return defaultLevel, line
+}
+
+// stripStdlogHeader removes the prefix of the standard logger and the date, time
+// and file from the start of the line. The prefix comes after the file when the
+// Lmsgprefix flag is set.
+func stripStdlogHeader(line string) string {
+	prefix := log.Prefix()
+
+	line = strings.TrimPrefix(line, prefix)
+	line = line[len(stdlogHeader.FindString(line)):]
+	return strings.TrimPrefix(line, prefix)
+}
+
+// detectedTag returns the tag the logging calls use for the level.
+func detectedTag(level int32) string {
+	if level == LEVEL_ERROR {
+		return "ERROR"
+	}
+
+	return "Info"
+}
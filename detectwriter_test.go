@@ -0,0 +1,63 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	stdlog "log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestLevelDetectingWriterStdlog writes through the standard logger with the
+// prefix and flags this package sets on it. The marker must decide the level,
+// and the line must name the caller of the standard logger without repeating
+// the date and file.
+func TestLevelDetectingWriterStdlog(t *testing.T) {
+	writer := &lineWriter{}
+	turnOnLogging(0, LEVEL_TRACE, writer)
+	defer turnOnLogging(0, 0, nil)
+
+	stdlog.SetOutput(LevelDetectingWriter(LEVEL_INFO))
+	defer stdlog.SetOutput(os.Stderr)
+
+	tests := []struct {
+		flags   int
+		message string
+		level   int32
+		text    string
+	}{
+		{stdlog.Ldate | stdlog.Ltime | stdlog.Lshortfile, "[ERROR] connection lost", LEVEL_ERROR, "connection lost"},
+		{stdlog.LstdFlags | stdlog.Lmicroseconds | stdlog.Llongfile, "WARN: retrying", LEVEL_WARN, "retrying"},
+		{stdlog.LstdFlags | stdlog.Lshortfile | stdlog.Lmsgprefix, "[INFO] connected", LEVEL_INFO, "connected"},
+		{stdlog.Ldate | stdlog.Ltime | stdlog.Lshortfile, "no marker", LEVEL_INFO, "no marker"},
+	}
+
+	flags := stdlog.Flags()
+	defer stdlog.SetFlags(flags)
+
+	for _, tt := range tests {
+		writer.lines = nil
+		stdlog.SetFlags(tt.flags)
+		stdlog.Print(tt.message)
+
+		if len(writer.lines) != 1 {
+			t.Fatalf("Print(%q) wrote %d lines, want 1", tt.message, len(writer.lines))
+		}
+
+		line := writer.lines[0]
+		if !strings.HasPrefix(line, LevelName(tt.level)+": ") {
+			t.Errorf("Print(%q) wrote %q, want level %s", tt.message, line, LevelName(tt.level))
+		}
+
+		if !strings.Contains(line, "detectwriter_test.go:") {
+			t.Errorf("Print(%q) wrote %q, want the caller in detectwriter_test.go", tt.message, line)
+		}
+
+		if !strings.HasSuffix(line, ": "+tt.text+"\n") || strings.Count(line, ".go:") != 1 {
+			t.Errorf("Print(%q) wrote %q, want the message %q without the header", tt.message, line, tt.text)
+		}
+	}
+}
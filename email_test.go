@@ -0,0 +1,57 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestConfigureEmailWhileSending changes the email configuration while alerts
+// are being sent. Run it with go test -race. Every email must be sent with one
+// whole configuration, never the user of one and the recipients of another.
+func TestConfigureEmailWhileSending(t *testing.T) {
+	defer Restore(Snapshot())
+
+	sender := NewRecordingMailSender()
+	SetMailSender(sender)
+	ConfigureEmail("localhost", 25, "user0", "password", []string{"user0@example.com"})
+
+	const senders, sends = 4, 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < sends; n++ {
+				user := fmt.Sprintf("user%d", i*sends+n)
+				ConfigureEmail("localhost", 25, user, "password", []string{user + "@example.com"})
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			for n := 0; n < sends; n++ {
+				SendEmailException("Race", "Alert[%d]", n)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sent := sender.Sent()
+	if len(sent) != senders*sends {
+		t.Fatalf("sent %d emails, want %d", len(sent), senders*sends)
+	}
+
+	for _, mail := range sent {
+		if len(mail.To) != 1 || !strings.HasPrefix(mail.To[0], mail.From+"@") {
+			t.Errorf("email from %s was sent to %v", mail.From, mail.To)
+		}
+	}
+}
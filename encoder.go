@@ -0,0 +1,539 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Format selects one of the built-in encoders.
+type Format int
+
+const (
+	FormatText        Format = iota // TRACE: 2013/11/07 08:24:32 main.go:12: main : main : Info : Hello
+	FormatJSON                      // {"time":"...","level":"TRACE","title":"main",...}
+	FormatLogfmt                    // time=... level=TRACE title=main ...
+	FormatJSONCompact               // {"t":1383812672000,"l":"TRACE","m":"main : main : Info : Hello"}
+	FormatCSV                       // 2013-11-07T08:24:32Z,TRACE,main,main,Hello,
+	FormatSdDaemon                  // <7>TRACE: 2013/11/07 08:24:32 main.go:12: main : main : Info : Hello
+)
+
+// Field is a key/value pair attached to a log record.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Record contains everything known about a single log line. The fields are
+// kept in the order they were added.
+type Record struct {
+	Level    int32
+	Time     time.Time
+	Title    string
+	Function string
+	Tag      string
+	Message  string
+	Err      error
+	Caller   string
+	Fields   []Field
+
+	// The format string and arguments of the log call, added as fields when
+	// SetIncludeTemplate is on.
+	template string
+	args     []interface{}
+
+	// errorCall is set by Error, CompletedError and their variants, the calls
+	// the nil error policy applies to.
+	errorCall bool
+
+	// caller is the location of the log call when it's known before output,
+	// so output doesn't look it up.
+	caller *callerInfo
+
+	// prefixes and levelFlags are the text format settings in use when the
+	// record was emitted, taken under the lock.
+	prefixes   map[int32]string
+	levelFlags map[int32]int
+}
+
+// Encoder converts a record into the bytes written to a destination.
+// Each call must return a complete line including the line terminator.
+// Records are encoded outside the Serialize lock, so Encode may be called
+// from several goroutines at once. The built-in encoders write the fields in
+// the order of Record.Fields so the output is the same on every run, custom
+// encoders should do the same.
+type Encoder interface {
+	Encode(rec Record) []byte
+}
+
+// HeaderEncoder is implemented by encoders whose files start with a header,
+// such as the column names of the CSV format. The header is written at the
+// start of every log file created while the encoder is in use, so set the
+// encoder before StartFile.
+type HeaderEncoder interface {
+	Encoder
+	Header() []byte
+}
+
+// SetEncoder changes the encoder used to write every log line.
+func SetEncoder(encoder Encoder) {
+	logger.Serialize.Lock()
+	logger.Encoder = encoder
+	logger.Serialize.Unlock()
+}
+
+// SetFormat changes the encoder to one of the built-in formats.
+func SetFormat(format Format) {
+	SetEncoder(formatEncoder(format))
+}
+
+// SetConsoleEncoder changes the encoder used for the lines written to stdout
+// and stderr, leaving the file and the sinks with the encoder set by SetEncoder.
+// Sinks are given their own encoder by NewWriterSink. Pass nil to go back to
+// the shared encoder.
+//
+//	log.SetFormat(log.FormatJSON)
+//	log.SetConsoleEncoder(log.TextEncoder{})
+func SetConsoleEncoder(encoder Encoder) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	logger.ConsoleEncoder = encoder
+	logger.setHandles(logger.ConsoleLevel, logger.FileLevel)
+}
+
+// SetFileEncoder changes the encoder used for the lines written to the log
+// file, leaving the console with the encoder set by SetEncoder. Set it before
+// StartFile when the encoder writes a header. Pass nil to go back to the shared
+// encoder.
+func SetFileEncoder(encoder Encoder) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	logger.FileEncoder = encoder
+	logger.setHandles(logger.ConsoleLevel, logger.FileLevel)
+}
+
+// SetConsoleFormat changes the console encoder to one of the built-in formats.
+func SetConsoleFormat(format Format) {
+	SetConsoleEncoder(formatEncoder(format))
+}
+
+// SetFileFormat changes the file encoder to one of the built-in formats.
+func SetFileFormat(format Format) {
+	SetFileEncoder(formatEncoder(format))
+}
+
+// formatEncoder returns the encoder for one of the built-in formats.
+func formatEncoder(format Format) Encoder {
+	switch format {
+	case FormatJSON:
+		logger.Serialize.Lock()
+		defer logger.Serialize.Unlock()
+
+		return JSONEncoder{TitleDelimiter: logger.TitleDelimiter}
+	case FormatLogfmt:
+		return LogfmtEncoder{}
+	case FormatJSONCompact:
+		return CompactJSONEncoder{}
+	case FormatCSV:
+		return CSVEncoder{}
+	case FormatSdDaemon:
+		return SdDaemonEncoder{}
+	default:
+		return TextEncoder{}
+	}
+}
+
+// SetTitleDelimiter has the JSON format split titles such as app.db.pool on the
+// delimiter into a namespace array so backends can facet on each part. It applies
+// to the JSON encoders in use and to the ones SetFormat creates later. Pass "" to
+// stop splitting.
+func SetTitleDelimiter(delimiter string) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	logger.TitleDelimiter = delimiter
+
+	if jsonEncoder, ok := logger.Encoder.(JSONEncoder); ok {
+		jsonEncoder.TitleDelimiter = delimiter
+		logger.Encoder = jsonEncoder
+	}
+
+	if jsonEncoder, ok := logger.ConsoleEncoder.(JSONEncoder); ok {
+		jsonEncoder.TitleDelimiter = delimiter
+		logger.ConsoleEncoder = jsonEncoder
+	}
+
+	if jsonEncoder, ok := logger.FileEncoder.(JSONEncoder); ok {
+		jsonEncoder.TitleDelimiter = delimiter
+		logger.FileEncoder = jsonEncoder
+	}
+}
+
+// SetLineEnding changes the terminator written at the end of every line, such
+// as "\r\n" for Windows log viewers. The default is "\n". MaxLineLength limits
+// the message and does not count the terminator.
+func SetLineEnding(ending string) {
+	logger.Serialize.Lock()
+	logger.LineEnding = ending
+	logger.Serialize.Unlock()
+}
+
+// LevelName returns the name of the logging level used in the output.
+func LevelName(level int32) string {
+	switch level {
+	case LEVEL_TRACE:
+		return "TRACE"
+	case LEVEL_INFO:
+		return "INFO"
+	case LEVEL_WARN:
+		return "WARNING"
+	case LEVEL_ERROR:
+		return "ERROR"
+	}
+
+	return strconv.Itoa(int(level))
+}
+
+// SetLevelPrefixes overrides the prefixes the text format writes at the start of
+// each line. The defaults are "TRACE: ", "INFO: ", "WARNING: " and "ERROR: ".
+func SetLevelPrefixes(trace string, info string, warn string, err string) {
+	prefixes := map[int32]string{
+		LEVEL_TRACE: trace,
+		LEVEL_INFO:  info,
+		LEVEL_WARN:  warn,
+		LEVEL_ERROR: err,
+	}
+
+	logger.Serialize.Lock()
+	logger.Prefixes = prefixes
+	logger.Serialize.Unlock()
+}
+
+// levelPrefix returns the prefix written at the start of a text line. SetLevelPrefixes
+// replaces the map instead of changing it, so the record can hold on to it.
+func levelPrefix(rec Record) string {
+	if prefix, ok := rec.prefixes[rec.Level]; ok {
+		return prefix
+	}
+
+	return LevelName(rec.Level) + ": "
+}
+
+// defaultLevelFlags are the flags of the text format for levels without their own.
+const defaultLevelFlags = log.Ldate | log.Ltime | log.Lshortfile
+
+// SetLevelFlags sets the flags of the standard log package, such as log.Ltime,
+// that select the date, time and caller the text format writes for the level.
+// Use it to keep the caller on warnings and errors and drop it from the high
+// volume trace lines. Ldate, Ltime, Lmicroseconds, LUTC, Lshortfile and Llongfile
+// are supported, the file is always the short name. The default for every level
+// is log.Ldate | log.Ltime | log.Lshortfile.
+func SetLevelFlags(level int32, flags int) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	levelFlags := make(map[int32]int, len(logger.LevelFlags)+1)
+	for key, value := range logger.LevelFlags {
+		levelFlags[key] = value
+	}
+	levelFlags[level] = flags
+
+	logger.LevelFlags = levelFlags
+}
+
+// levelFlags returns the text format flags for the level of the record. SetLevelFlags
+// replaces the map instead of changing it, so the record can hold on to it.
+func levelFlags(rec Record) int {
+	if flags, ok := rec.levelFlags[rec.Level]; ok {
+		return flags
+	}
+
+	return defaultLevelFlags
+}
+
+// writeTextHeader writes the date, time and caller selected by the flags.
+func writeTextHeader(buf *bytes.Buffer, rec Record, flags int) {
+	t := rec.Time
+	if flags&log.LUTC != 0 {
+		t = t.UTC()
+	}
+
+	if flags&log.Ldate != 0 {
+		buf.WriteString(t.Format("2006/01/02 "))
+	}
+
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		if flags&log.Lmicroseconds != 0 {
+			buf.WriteString(t.Format("15:04:05.000000 "))
+		} else {
+			buf.WriteString(t.Format("15:04:05 "))
+		}
+	}
+
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		buf.WriteString(rec.Caller)
+		buf.WriteString(": ")
+	}
+}
+
+// recordText joins the title, function, tag, message and error the way the
+// text format writes them.
+//
+//	main : main : ERROR : Hello Error : Exception At...
+func recordText(rec Record) string {
+	text := fmt.Sprintf("%s : %s : %s", rec.Title, rec.Function, rec.Tag)
+	if rec.Message != "" {
+		text += " : " + rec.Message
+	}
+
+	if rec.Err != nil {
+		text += " : " + rec.Err.Error()
+	}
+
+	return text
+}
+
+//** TEXT
+
+// TextEncoder writes the traditional tracelog line.
+//
+//	TRACE: 2013/11/07 08:24:32 main.go:12: main : main : Info : Hello Trace
+type TextEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (TextEncoder) Encode(rec Record) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(levelPrefix(rec))
+	writeTextHeader(&buf, rec, levelFlags(rec))
+
+	buf.WriteString(recordText(rec))
+
+	for i, field := range rec.Fields {
+		if i == 0 {
+			buf.WriteString(" :")
+		}
+		fmt.Fprintf(&buf, " %s[%v]", field.Key, field.Value)
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+//** JSON
+
+// JSONEncoder writes each line as a single JSON object.
+type JSONEncoder struct {
+	// Severity adds the level as a numeric syslog severity, 3 for errors to 7
+	// for trace, so backends can filter on a range such as severity <= 4.
+	Severity bool
+
+	// TitleDelimiter adds the title split on the delimiter as a namespace
+	// array, so a title of app.db.pool with "." becomes ["app","db","pool"].
+	TitleDelimiter string
+}
+
+// Encode implements the Encoder interface.
+func (jsonEncoder JSONEncoder) Encode(rec Record) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	writeJSONField(&buf, "time", rec.Time.Format(time.RFC3339Nano), true)
+	writeJSONField(&buf, "level", LevelName(rec.Level), false)
+	if jsonEncoder.Severity {
+		writeJSONField(&buf, "severity", syslogSeverity(rec.Level), false)
+	}
+	writeJSONField(&buf, "title", rec.Title, false)
+	if jsonEncoder.TitleDelimiter != "" {
+		writeJSONField(&buf, "namespace", strings.Split(rec.Title, jsonEncoder.TitleDelimiter), false)
+	}
+	writeJSONField(&buf, "function", rec.Function, false)
+	writeJSONField(&buf, "tag", rec.Tag, false)
+	if rec.Message != "" {
+		writeJSONField(&buf, "message", rec.Message, false)
+	}
+	if rec.Err != nil {
+		writeJSONField(&buf, "error", rec.Err.Error(), false)
+	}
+	writeJSONField(&buf, "caller", rec.Caller, false)
+
+	for _, field := range rec.Fields {
+		writeJSONField(&buf, field.Key, field.Value, false)
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// writeJSONField writes a key/value pair to the JSON object being built.
+func writeJSONField(buf *bytes.Buffer, key string, value interface{}, first bool) {
+	if !first {
+		buf.WriteByte(',')
+	}
+
+	k, _ := json.Marshal(key)
+	buf.Write(k)
+	buf.WriteByte(':')
+
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(v)
+}
+
+// CompactJSONEncoder writes a lean JSON object for high volume logging. It uses
+// short keys, a millisecond unix time and leaves out the caller. The title,
+// function, tag, message and error are joined into the message.
+type CompactJSONEncoder struct {
+	// Severity adds the level as a numeric syslog severity under the s key.
+	Severity bool
+}
+
+// Encode implements the Encoder interface.
+func (compactJSONEncoder CompactJSONEncoder) Encode(rec Record) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	writeJSONField(&buf, "t", rec.Time.UnixNano()/int64(time.Millisecond), true)
+	writeJSONField(&buf, "l", LevelName(rec.Level), false)
+	if compactJSONEncoder.Severity {
+		writeJSONField(&buf, "s", syslogSeverity(rec.Level), false)
+	}
+	writeJSONField(&buf, "m", recordText(rec), false)
+
+	for _, field := range rec.Fields {
+		writeJSONField(&buf, field.Key, field.Value, false)
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+//** LOGFMT
+
+// LogfmtEncoder writes each line as space separated key=value pairs.
+type LogfmtEncoder struct {
+	// Severity adds the level as a numeric syslog severity.
+	Severity bool
+}
+
+// Encode implements the Encoder interface.
+func (logfmtEncoder LogfmtEncoder) Encode(rec Record) []byte {
+	var buf bytes.Buffer
+
+	writeLogfmtField(&buf, "time", rec.Time.Format(time.RFC3339Nano), true)
+	writeLogfmtField(&buf, "level", LevelName(rec.Level), false)
+	if logfmtEncoder.Severity {
+		writeLogfmtField(&buf, "severity", syslogSeverity(rec.Level), false)
+	}
+	writeLogfmtField(&buf, "title", rec.Title, false)
+	writeLogfmtField(&buf, "function", rec.Function, false)
+	writeLogfmtField(&buf, "tag", rec.Tag, false)
+	if rec.Message != "" {
+		writeLogfmtField(&buf, "msg", rec.Message, false)
+	}
+	if rec.Err != nil {
+		writeLogfmtField(&buf, "error", rec.Err.Error(), false)
+	}
+	writeLogfmtField(&buf, "caller", rec.Caller, false)
+
+	for _, field := range rec.Fields {
+		writeLogfmtField(&buf, field.Key, field.Value, false)
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// writeLogfmtField writes a key=value pair, quoting the value when required.
+func writeLogfmtField(buf *bytes.Buffer, key string, value interface{}, first bool) {
+	if !first {
+		buf.WriteByte(' ')
+	}
+
+	v := fmt.Sprint(value)
+	if v == "" || strings.ContainsAny(v, " =\"\t\r\n") {
+		v = strconv.Quote(v)
+	}
+
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	buf.WriteString(v)
+}
+
+//** CSV
+
+// csvColumns are the columns written by the CSV encoder, in order.
+var csvColumns = []string{"time", "level", "title", "function", "message", "error"}
+
+// CSVEncoder writes each line as a CSV row of the time, level, title, function,
+// message and error, quoted per RFC 4180 so separators, quotes and line breaks
+// in the values are kept. The tag, caller and fields are not written, so the
+// columns never change. Use SetLineEnding("\r\n") for readers that require
+// the RFC 4180 line terminator.
+//
+//	log.SetEncoder(log.CSVEncoder{Separator: ';'})
+type CSVEncoder struct {
+	// Separator is the column separator, a comma when it's 0 or a character
+	// that can't separate columns such as a quote or a line break.
+	Separator rune
+}
+
+// Header implements the HeaderEncoder interface with the column names.
+func (csvEncoder CSVEncoder) Header() []byte {
+	return csvEncoder.row(csvColumns)
+}
+
+// Encode implements the Encoder interface.
+func (csvEncoder CSVEncoder) Encode(rec Record) []byte {
+	var errText string
+	if rec.Err != nil {
+		errText = rec.Err.Error()
+	}
+
+	return csvEncoder.row([]string{
+		rec.Time.Format(time.RFC3339Nano),
+		LevelName(rec.Level),
+		rec.Title,
+		rec.Function,
+		rec.Message,
+		errText,
+	})
+}
+
+// row writes the values as one CSV row.
+func (csvEncoder CSVEncoder) row(values []string) []byte {
+	var buf bytes.Buffer
+
+	writer := csv.NewWriter(&buf)
+	writer.Comma = csvEncoder.Separator
+	switch {
+	case !utf8.ValidRune(writer.Comma), writer.Comma == utf8.RuneError:
+		writer.Comma = ','
+	case writer.Comma == 0, writer.Comma == '"', writer.Comma == '\r', writer.Comma == '\n':
+		writer.Comma = ','
+	}
+
+	// Writing to a buffer can't fail and the separator has been checked.
+	writer.Write(values)
+	writer.Flush()
+
+	return buf.Bytes()
+}
@@ -0,0 +1,88 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"sort"
+	"sync"
+)
+
+// errorSummary counts the error lines by text and call site.
+type errorSummary struct {
+	mutex  sync.Mutex
+	top    int
+	counts map[errorSite]int
+}
+
+// errorSite identifies an error line by its text and the call site.
+type errorSite struct {
+	text   string
+	caller string
+}
+
+// SetErrorSummary counts the error lines by their text and call site while the
+// program runs and has Stop write the top entries by count, such as
+// "Count[42] Caller[db.go:88] : db : Query : ERROR : connection refused". Pass
+// 0 to turn it off, which is the default.
+func SetErrorSummary(top int) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	if top <= 0 {
+		logger.ErrorSummary = nil
+		return
+	}
+
+	logger.ErrorSummary = &errorSummary{top: top, counts: make(map[errorSite]int)}
+}
+
+// add counts the error record.
+func (errorSummary *errorSummary) add(rec Record) {
+	errorSummary.mutex.Lock()
+	errorSummary.counts[errorSite{text: recordText(rec), caller: rec.Caller}]++
+	errorSummary.mutex.Unlock()
+}
+
+// writeErrorSummary writes the top error entries to the Info destination.
+func (traceLog *traceLog) writeErrorSummary() {
+	traceLog.Serialize.Lock()
+	summary := traceLog.ErrorSummary
+	traceLog.Serialize.Unlock()
+
+	if summary == nil {
+		return
+	}
+
+	summary.mutex.Lock()
+	counts := make(map[errorSite]int, len(summary.counts))
+	sites := make([]errorSite, 0, len(summary.counts))
+	total := 0
+	for site, count := range summary.counts {
+		counts[site] = count
+		sites = append(sites, site)
+		total += count
+	}
+	summary.mutex.Unlock()
+
+	if total == 0 {
+		return
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		if counts[sites[i]] != counts[sites[j]] {
+			return counts[sites[i]] > counts[sites[j]]
+		}
+		return sites[i].text < sites[j].text
+	})
+
+	if len(sites) > summary.top {
+		sites = sites[:summary.top]
+	}
+
+	Info("main", "ErrorSummary", "Errors[%d] Distinct[%d]", total, len(counts))
+	for _, site := range sites {
+		Info("main", "ErrorSummary", "Count[%d] Caller[%s] : %s", counts[site], site.caller, site.text)
+	}
+}
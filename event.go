@@ -0,0 +1,98 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"time"
+)
+
+// SetEventWriter sends the lines written by Event to the writer. By default
+// events are written to the Info destination. Pass nil to go back to the default.
+// Writes to the writer are given up after the write timeout like the writes to
+// the other destinations.
+func SetEventWriter(w io.Writer) {
+	var eventWriter io.Writer
+	if w != nil {
+		eventWriter = &destination{writer: w, busy: new(int32)}
+	}
+
+	logger.Serialize.Lock()
+	logger.EventWriter = eventWriter
+	logger.Serialize.Unlock()
+}
+
+// Event writes a business event, such as user_signup, with its attributes.
+// Events are always written as a single JSON object regardless of the format
+// used for the diagnostic logs, and they are not sent to the sinks.
+//
+//	{"time":"...","event":"user_signup","plan":"pro","user_id":42}
+func Event(name string, fields map[string]interface{}) {
+	rec := Record{Level: LEVEL_INFO, Time: time.Now(), Tag: "Event", Message: name}
+	if len(fields) > 0 {
+		rec.Fields = mapFields(reflect.ValueOf(fields))
+	}
+
+	logEvent(rec)
+}
+
+// Eventw writes a business event like Event with the attributes in the order
+// they are passed rather than sorted by key.
+//
+//	log.Eventw("user_signup", log.Field{Key: "user_id", Value: 42}, log.Field{Key: "plan", Value: "pro"})
+func Eventw(name string, fields ...Field) {
+	logEvent(Record{Level: LEVEL_INFO, Time: time.Now(), Tag: "Event", Message: name, Fields: fields})
+}
+
+// logEvent writes the event to the event writer or the Info destination. Events
+// are only written while the Info level is enabled, so Pause holds them too.
+func logEvent(rec Record) {
+	if !logger.enabled(LEVEL_INFO) {
+		return
+	}
+
+	logger.sanitizeKeys(&rec)
+
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	handles := []io.Writer{logger.EventWriter}
+	if logger.EventWriter == nil {
+		handles = []io.Writer{logger.handle(LEVEL_INFO), logger.fileHandle(LEVEL_INFO)}
+	}
+
+	var line []byte
+	for _, handle := range handles {
+		if handle == nil || handle == ioutil.Discard {
+			continue
+		}
+
+		if line == nil {
+			line = applyLineEnding(encodeEvent(logger.redact(rec)), logger.LineEnding)
+		}
+
+		logger.writeHandle(handle, line)
+	}
+}
+
+// encodeEvent writes the event as a JSON object. The time and event keys come
+// first followed by the attributes in the order of the record's fields.
+func encodeEvent(rec Record) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	writeJSONField(&buf, "time", rec.Time.Format(time.RFC3339Nano), true)
+	writeJSONField(&buf, "event", rec.Message, false)
+
+	for _, field := range rec.Fields {
+		writeJSONField(&buf, field.Key, field.Value, false)
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,65 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+//go:build windows
+// +build windows
+
+package log
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventLogID is the event id written with every record.
+const eventLogID = 1
+
+// StartEventLog initializes tracelog to write the specified logging level to the
+// Windows Event Log under the event source. Errors are written as error events,
+// warnings as warning events and everything else as information events. The
+// source must be registered, such as with eventlog.InstallAsEventCreate, when
+// the service is installed. Nothing is written to the console since a service
+// has none.
+func StartEventLog(source string, logLevel int32) error {
+	events, err := eventlog.Open(source)
+	if err != nil {
+		return err
+	}
+
+	turnOnLogging(0, 0, nil)
+	AddSink(logLevel, &eventLogSink{events: events})
+
+	return nil
+}
+
+// eventLogSink sends records to the Windows Event Log.
+type eventLogSink struct {
+	events *eventlog.Log
+}
+
+// WriteRecord implements the Sink interface.
+func (eventLogSink *eventLogSink) WriteRecord(rec Record) error {
+	message := fmt.Sprintf("%s: %s", rec.Caller, recordText(rec))
+	for i, field := range rec.Fields {
+		if i == 0 {
+			message += " :"
+		}
+		message += fmt.Sprintf(" %s[%v]", field.Key, field.Value)
+	}
+
+	switch rec.Level {
+	case LEVEL_ERROR:
+		return eventLogSink.events.Error(eventLogID, message)
+	case LEVEL_WARN:
+		return eventLogSink.events.Warning(eventLogID, message)
+	}
+
+	return eventLogSink.events.Info(eventLogID, message)
+}
+
+// Close closes the event log handle.
+func (eventLogSink *eventLogSink) Close() error {
+	return eventLogSink.events.Close()
+}
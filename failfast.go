@@ -0,0 +1,42 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// failFastExiting is set once the first error has started the exit, so errors
+// written while stopping don't start it again.
+var failFastExiting int32
+
+// SetFailFast makes the first error logged by the application end the
+// program, like set -e in a shell script. It applies to Error, CompletedError,
+// Alert and their variants when the line is written, not to the errors this
+// package logs about itself. The email of an Alert is sent first, then the
+// logging is stopped so the files are flushed and the alerts being sent
+// finish, and the program exits with status 1. It's meant for command line
+// tools, the default is off.
+func SetFailFast(failFast bool) {
+	logger.Serialize.Lock()
+	logger.FailFast = failFast
+	logger.Serialize.Unlock()
+}
+
+// failFast stops the logging and exits when fail fast is on. It's called after
+// an error of the application has been written and its alerts sent.
+func (traceLog *traceLog) failFast() {
+	traceLog.Serialize.Lock()
+	failFast := traceLog.FailFast
+	traceLog.Serialize.Unlock()
+
+	if !failFast || !atomic.CompareAndSwapInt32(&failFastExiting, 0, 1) {
+		return
+	}
+
+	Stop()
+	os.Exit(1)
+}
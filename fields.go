@@ -0,0 +1,31 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"strconv"
+	"time"
+)
+
+// Duration returns a field with the duration in milliseconds to 3 decimals, such
+// as 12.345ms. Every format writes the same text so durations compare the same
+// way in every log.
+//
+//	log.CompletedResult("main", "Import", log.OutcomeSuccess, log.Duration("elapsed", time.Since(start)))
+func Duration(key string, d time.Duration) Field {
+	ms := float64(d) / float64(time.Millisecond)
+	return Field{Key: key, Value: strconv.FormatFloat(ms, 'f', 3, 64) + "ms"}
+}
+
+// Rate returns a field with the count per second over the duration to 3
+// decimals, such as 250.000/s. A duration of 0 or less gives a rate of 0.
+func Rate(key string, count int, over time.Duration) Field {
+	var rate float64
+	if over > 0 {
+		rate = float64(count) / over.Seconds()
+	}
+
+	return Field{Key: key, Value: strconv.FormatFloat(rate, 'f', 3, 64) + "/s"}
+}
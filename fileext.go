@@ -0,0 +1,111 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var (
+	// fileExtension is the extension of the log file.
+	fileExtension = "txt"
+
+	// levelFileExtensions holds the extension of the separate file for a level.
+	levelFileExtensions map[int32]string
+)
+
+// SetFileExtension sets the extension of the log file, such as "log". Call it
+// before StartFile. The default is "txt".
+func SetFileExtension(ext string) {
+	ext = strings.TrimPrefix(ext, ".")
+	if ext == "" {
+		ext = "txt"
+	}
+
+	fileExtension = ext
+}
+
+// SetLevelFileExtension writes the lines of the level to a separate file with
+// the extension, such as "err" for errors, next to the log file in the same date
+// directory. The file level still decides if the lines are written. Call it
+// before StartFile, an empty extension sends the level back to the log file.
+//
+//	log.SetFileExtension("log")
+//	log.SetLevelFileExtension(log.LEVEL_ERROR, "err")
+func SetLevelFileExtension(logLevel int32, ext string) {
+	ext = strings.TrimPrefix(ext, ".")
+
+	if ext == "" || ext == fileExtension {
+		delete(levelFileExtensions, logLevel)
+		return
+	}
+
+	if levelFileExtensions == nil {
+		levelFileExtensions = make(map[int32]string)
+	}
+
+	levelFileExtensions[logLevel] = ext
+}
+
+// createLevelFiles creates the separate files for the levels with their own
+// extension. Levels sharing an extension share a file.
+func createLevelFiles(baseFilePath string) (map[int32]*os.File, error) {
+	if len(levelFileExtensions) == 0 {
+		return nil, nil
+	}
+
+	files := make(map[int32]*os.File, len(levelFileExtensions))
+	byExtension := make(map[string]*os.File)
+
+	for logLevel, ext := range levelFileExtensions {
+		logf, ok := byExtension[ext]
+		if !ok {
+			var err error
+			if logf, err = createLogFile(baseFilePath, ext); err != nil {
+				closeLevelFiles(files)
+				return nil, err
+			}
+			byExtension[ext] = logf
+		}
+
+		files[logLevel] = logf
+	}
+
+	return files, nil
+}
+
+// closeLevelFiles closes the separate level files once each.
+func closeLevelFiles(files map[int32]*os.File) error {
+	var err error
+	closed := make(map[*os.File]bool)
+
+	for _, logf := range files {
+		if closed[logf] {
+			continue
+		}
+		closed[logf] = true
+
+		if closeErr := logf.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// levelFile returns the separate file for the level in place of the file handle
+// when the level has one and the handle is enabled. The handle may be wrapped,
+// such as by the rolling counter, so only a disabled handle is kept. The Serialize
+// lock must be held by the caller.
+func (traceLog *traceLog) levelFile(destinations map[io.Writer]*destination, logLevel int32, handle io.Writer) io.Writer {
+	if logf, ok := traceLog.LevelFiles[logLevel]; ok && handle != ioutil.Discard {
+		return traceLog.destination(destinations, logf, logf, true)
+	}
+
+	return handle
+}
@@ -0,0 +1,100 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// exclusiveFileLock is set by SetExclusiveFileLock.
+var exclusiveFileLock bool
+
+// SetExclusiveFileLock has StartFile take an exclusive lock on a lock file named
+// after the program, such as orders.lock, in the base file path. StartFile fails
+// when another process holds the lock, so a second instance of a singleton
+// daemon can't write to the same logs. The lock file holds the pid of the owner
+// and the lock is released by Stop. Call it before StartFile.
+func SetExclusiveFileLock(exclusive bool) {
+	exclusiveFileLock = exclusive
+}
+
+// fileLock returns the lock file for StartFile. A lock still held from an
+// earlier StartFile without a Stop is kept when it's for the same base file path
+// and released otherwise, once the new lock is taken. No lock is taken unless
+// SetExclusiveFileLock is on.
+func fileLock(baseFilePath string) (*os.File, error) {
+	logger.Serialize.Lock()
+	held := logger.LockFile
+	logger.Serialize.Unlock()
+
+	if !exclusiveFileLock {
+		if held != nil {
+			held.Close()
+		}
+		return nil, nil
+	}
+
+	if held != nil && sameFile(held, lockFileName(baseFilePath)) {
+		return held, nil
+	}
+
+	lockf, err := acquireFileLock(baseFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if held != nil {
+		held.Close()
+	}
+
+	return lockf, nil
+}
+
+// lockFileName returns the name of the lock file for the program.
+func lockFileName(baseFilePath string) string {
+	return filepath.Join(baseFilePath, filepath.Base(os.Args[0])+".lock")
+}
+
+// sameFile reports if the open file is the file with the name.
+func sameFile(f *os.File, name string) bool {
+	openInfo, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(openInfo, info)
+}
+
+// acquireFileLock opens the lock file for the program and locks it.
+func acquireFileLock(baseFilePath string) (*os.File, error) {
+	if err := os.MkdirAll(baseFilePath, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("Failed to Create log directory : %s : %s", baseFilePath, err)
+	}
+
+	name := lockFileName(baseFilePath)
+
+	lockf, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to Open lock file : %s : %s", name, err)
+	}
+
+	if err := lockFile(lockf); err != nil {
+		lockf.Close()
+		return nil, fmt.Errorf("Failed to Lock log files, another process holds the lock : %s : %s", name, err)
+	}
+
+	lockf.Truncate(0)
+	lockf.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+
+	return lockf, nil
+}
@@ -0,0 +1,18 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package log
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile reports that file locks aren't supported on this platform.
+func lockFile(lockf *os.File) error {
+	return errors.New("file locks are not supported on this platform")
+}
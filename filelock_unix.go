@@ -0,0 +1,18 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on the file without waiting for it.
+func lockFile(lockf *os.File) error {
+	return syscall.Flock(int(lockf.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
@@ -0,0 +1,41 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package log
+
+import "testing"
+
+// TestStartFileAgainKeepsLock starts the file logging twice without a Stop. The
+// lock on the same base file path must be kept, and the lock on a path that is
+// no longer used must be released.
+func TestStartFileAgainKeepsLock(t *testing.T) {
+	SetExclusiveFileLock(true)
+	defer SetExclusiveFileLock(false)
+
+	first, second := t.TempDir(), t.TempDir()
+
+	StartFile(LEVEL_INFO, first, 0)
+	held := logger.LockFile
+
+	StartFile(LEVEL_INFO, first, 0)
+	if logger.LockFile != held {
+		t.Fatal("StartFile on the same path didn't keep the lock")
+	}
+
+	StartFile(LEVEL_INFO, second, 0)
+	defer Stop()
+
+	lockf, err := acquireFileLock(first)
+	if err != nil {
+		t.Fatalf("the lock on the first path wasn't released : %s", err)
+	}
+	lockf.Close()
+
+	if _, err := acquireFileLock(second); err == nil {
+		t.Fatal("the lock on the second path isn't held")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+// AddFilter registers a filter that sees every record before it's encoded.
+// The filter can change the record, such as rewriting the message or adding
+// fields, or return false to drop it. Filters run in the order they were added
+// and the first one to return false stops the rest.
+//
+//	log.AddFilter(func(rec *log.Record) bool {
+//	    return rec.Function != "HealthCheck"
+//	})
+func AddFilter(filter func(rec *Record) bool) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	// Copy on write so output can use the slice without holding the lock.
+	filters := make([]func(rec *Record) bool, 0, len(logger.Filters)+1)
+	filters = append(filters, logger.Filters...)
+	logger.Filters = append(filters, filter)
+}
+
+// ClearFilters removes every filter registered by AddFilter.
+func ClearFilters() {
+	logger.Serialize.Lock()
+	logger.Filters = nil
+	logger.Serialize.Unlock()
+}
+
+// filter runs the filters over the record and reports if it should be written.
+func (traceLog *traceLog) filter(rec *Record) bool {
+	traceLog.Serialize.Lock()
+	filters := traceLog.Filters
+	traceLog.Serialize.Unlock()
+
+	if len(filters) == 0 {
+		return true
+	}
+
+	// A filter may change the fields, which still belong to the caller.
+	rec.Fields = append([]Field(nil), rec.Fields...)
+
+	for _, filter := range filters {
+		if !filter(rec) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// SetFlushInterval buffers writes to the log file and flushes the buffer every
+// interval, trading a bounded delay for fewer write system calls. The buffer is
+// also flushed after every error line and when the file is rotated or closed.
+// An interval of 0 flushes the buffer and returns to unbuffered writes.
+func SetFlushInterval(interval time.Duration) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	logger.stopFlusher()
+
+	if logger.LogFile == nil {
+		return
+	}
+
+	if interval <= 0 {
+		logger.FileBuffer = nil
+		logger.FileHandle = logger.fileBase()
+		logger.setHandles(logger.ConsoleLevel, logger.FileLevel)
+
+		// A compressed file must still be flushed to be readable.
+		if logger.GzipWriter != nil {
+			logger.startFlusher(gzipFlushInterval)
+		}
+		return
+	}
+
+	logger.FileBuffer = bufio.NewWriter(logger.fileBase())
+	logger.FileHandle = logger.FileBuffer
+	logger.setHandles(logger.ConsoleLevel, logger.FileLevel)
+	logger.startFlusher(interval)
+}
+
+// fileBase returns the writer below the file buffer, the gzip writer when the
+// file is compressed.
+func (traceLog *traceLog) fileBase() io.Writer {
+	if traceLog.GzipWriter != nil {
+		return traceLog.GzipWriter
+	}
+
+	return traceLog.LogFile
+}
+
+// startFlusher flushes the file every interval in the background. The
+// Serialize lock must be held by the caller.
+func (traceLog *traceLog) startFlusher(interval time.Duration) {
+	stop := make(chan struct{})
+	traceLog.FlushStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				traceLog.Serialize.Lock()
+				traceLog.flush()
+				traceLog.Serialize.Unlock()
+
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// flush writes any buffered lines to the log file. The Serialize lock must be
+// held by the caller.
+func (traceLog *traceLog) flush() error {
+	if traceLog.FileBuffer == nil && traceLog.GzipWriter == nil {
+		return nil
+	}
+
+	// An abandoned write may still be using the buffer.
+	if traceLog.fileBusy() {
+		return nil
+	}
+
+	if traceLog.FileBuffer != nil {
+		if err := traceLog.FileBuffer.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if traceLog.GzipWriter != nil {
+		return traceLog.GzipWriter.Flush()
+	}
+
+	return nil
+}
+
+// stopFlusher flushes the buffer and ends the background flusher. The Serialize
+// lock must be held by the caller.
+func (traceLog *traceLog) stopFlusher() error {
+	if traceLog.FlushStop != nil {
+		close(traceLog.FlushStop)
+		traceLog.FlushStop = nil
+	}
+
+	return traceLog.flush()
+}
@@ -0,0 +1,137 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+const (
+	gelfChunkSize = 8192 // Maximum UDP datagram size accepted by Graylog
+	gelfMaxChunks = 128  // Maximum number of chunks per message
+)
+
+// gelfChunkMagic starts every chunk of a chunked GELF message.
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// The host sent with every GELF message, looked up on the first one.
+var (
+	gelfHostOnce sync.Once
+	gelfHostName string
+)
+
+// gelfHost returns the name of the host for the GELF messages.
+func gelfHost() string {
+	gelfHostOnce.Do(func() {
+		gelfHostName, _ = os.Hostname()
+	})
+
+	return gelfHostName
+}
+
+// StartGELF initializes tracelog and only displays the specified logging level
+// and sends the same records as GELF messages to the Graylog UDP input at addr.
+func StartGELF(logLevel int32, addr string) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	turnOnLogging(logLevel, 0, nil)
+	AddSink(logLevel, NewWriterSink(&gelfWriter{conn: conn}, GELFEncoder{}))
+
+	return nil
+}
+
+// GELFEncoder writes each line as a GELF 1.1 message. The title, function, tag,
+// caller, error and fields are sent as additional fields.
+type GELFEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (GELFEncoder) Encode(rec Record) []byte {
+	var buf bytes.Buffer
+
+	shortMessage := rec.Message
+	if shortMessage == "" {
+		shortMessage = rec.Tag
+	}
+
+	buf.WriteByte('{')
+	writeJSONField(&buf, "version", "1.1", true)
+	writeJSONField(&buf, "host", gelfHost(), false)
+	writeJSONField(&buf, "short_message", shortMessage, false)
+	writeJSONField(&buf, "timestamp", float64(rec.Time.UnixNano())/1e9, false)
+	writeJSONField(&buf, "level", syslogSeverity(rec.Level), false)
+	writeJSONField(&buf, "_title", rec.Title, false)
+	writeJSONField(&buf, "_function", rec.Function, false)
+	writeJSONField(&buf, "_tag", rec.Tag, false)
+	writeJSONField(&buf, "_caller", rec.Caller, false)
+	if rec.Err != nil {
+		writeJSONField(&buf, "_error", rec.Err.Error(), false)
+	}
+
+	for _, field := range rec.Fields {
+		writeJSONField(&buf, "_"+field.Key, field.Value, false)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// gelfWriter sends GELF messages over UDP, chunking the ones too large for a datagram.
+type gelfWriter struct {
+	conn net.Conn
+}
+
+// Write implements the io.Writer interface.
+func (gelfWriter *gelfWriter) Write(p []byte) (int, error) {
+	setWriteDeadline(gelfWriter.conn)
+
+	if len(p) <= gelfChunkSize {
+		return gelfWriter.conn.Write(p)
+	}
+
+	// Each chunk carries a 12 byte header: magic, message id, sequence number and count.
+	dataSize := gelfChunkSize - 12
+	count := (len(p) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return 0, fmt.Errorf("gelf message of %d bytes needs %d chunks, limit is %d", len(p), count, gelfMaxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return 0, err
+	}
+
+	chunk := make([]byte, 0, gelfChunkSize)
+	for i := 0; i < count; i++ {
+		start := i * dataSize
+		end := start + dataSize
+		if end > len(p) {
+			end = len(p)
+		}
+
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, p[start:end]...)
+
+		if _, err := gelfWriter.conn.Write(chunk); err != nil {
+			return start, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close closes the UDP connection.
+func (gelfWriter *gelfWriter) Close() error {
+	return gelfWriter.conn.Close()
+}
@@ -0,0 +1,61 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CheckFileWritable verifies the log file is still in place and writable so a
+// health endpoint can catch a file deleted or made read only while the program
+// runs. Writes to such a file fail or go nowhere without any sign. Call RotateNow
+// to open a new file when it returns an error.
+func CheckFileWritable() error {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	if logger.LogFile == nil {
+		return errors.New("no log file to check")
+	}
+
+	if err := checkFileWritable(logger.LogFile); err != nil {
+		return err
+	}
+
+	for _, logf := range logger.LevelFiles {
+		if err := checkFileWritable(logf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkFileWritable verifies the path of the open file still names the file and
+// can be opened for writing.
+func checkFileWritable(logf *os.File) error {
+	open, err := logf.Stat()
+	if err != nil {
+		return fmt.Errorf("Failed to Stat log file : %s : %s", logf.Name(), err)
+	}
+
+	current, err := os.Stat(logf.Name())
+	if err != nil {
+		return fmt.Errorf("Failed to Stat log file : %s : %s", logf.Name(), err)
+	}
+
+	if !os.SameFile(open, current) {
+		return fmt.Errorf("Log file was replaced : %s", logf.Name())
+	}
+
+	test, err := os.OpenFile(logf.Name(), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("Log file is not writable : %s : %s", logf.Name(), err)
+	}
+
+	return test.Close()
+}
@@ -0,0 +1,261 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// responseWriter wraps a http.ResponseWriter to capture the status code.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader captures the status code before passing it on.
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.wroteHeader {
+		return
+	}
+
+	rw.status = status
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write records an implicit 200 status if no header has been written.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush passes the flush on when the underlying writer supports it.
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for use by http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Middleware wraps a handler and logs each request with its status code and latency.
+// Requests are logged to the Info destination, 5xx responses to the Error destination.
+// A panic in the handler is recovered, written as a single PANIC record with the
+// request and answered with a 500 unless the handler already wrote a status. A
+// panic with http.ErrAbortHandler is passed on so the server aborts the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				if !rw.wroteHeader {
+					http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+
+				// The PANIC record is the request's only line.
+				logger.logPanic(rec, "Middleware", fmt.Sprintf("Method[%s] Path[%s] Status[%d] Latency[%v]", r.Method, r.URL.Path, rw.status, time.Since(start)))
+				return
+			}
+
+			latency := time.Since(start)
+			if rw.status >= http.StatusInternalServerError {
+				err := fmt.Errorf("%d %s", rw.status, http.StatusText(rw.status))
+				internalErrorf(err, "Middleware", "Method[%s] Path[%s] Status[%d] Latency[%v]", r.Method, r.URL.Path, rw.status, latency)
+				return
+			}
+
+			Info("main", "Middleware", "Method[%s] Path[%s] Status[%d] Latency[%v]", r.Method, r.URL.Path, rw.status, latency)
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// RecoverMiddleware wraps a handler and recovers from any panic it raises. The
+// panic and stack trace are logged to the Error destination, an alert email is
+// sent with the request method, path and headers, and the client receives a 500.
+func RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				detail := fmt.Sprintf("Method[%s] Path[%s] Headers[%v]", r.Method, r.URL.Path, requestHeaders(r))
+				logger.logPanic(rec, "RecoverMiddleware", detail)
+
+				if !rw.wroteHeader {
+					http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// requestHeaders returns the request headers with credentials masked.
+func requestHeaders(r *http.Request) http.Header {
+	headers := r.Header.Clone()
+	for _, key := range credentialHeaders {
+		if headers.Get(key) != "" {
+			headers.Set(key, redactedValue)
+		}
+	}
+
+	return headers
+}
+
+//** CLIENT
+
+// credentialHeaders are the headers that are always masked.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// HTTPResponse writes a record for a call made with a http.Client, with the
+// method, url, status, elapsed time and the request and response headers as
+// fields. Responses with a 5xx status, or no response at all, are written to
+// the Error destination and the rest to the Info destination. The credential
+// headers are always masked, and the headers and query parameters named by
+// RegisterRedactKey are masked too. The patterns registered by
+// RegisterRedactPattern are applied to the url. When req is nil the request of
+// the response is used, and nothing is written when there is neither.
+//
+//	start := time.Now()
+//	resp, err := client.Do(req)
+//	if err != nil {
+//	    log.HTTPError("payments", req, err, time.Since(start))
+//	    return err
+//	}
+//	log.HTTPResponse("payments", req, resp, time.Since(start))
+func HTTPResponse(title string, req *http.Request, resp *http.Response, elapsed time.Duration) {
+	logHTTPCall(title, "HTTPResponse", req, resp, nil, elapsed)
+}
+
+// HTTPError writes a record to the Error destination for a call made with a
+// http.Client that failed without a response, such as a refused connection or
+// a timeout. The fields are the same as for HTTPResponse with a status of 0.
+// Nothing is written when req is nil.
+func HTTPError(title string, req *http.Request, err error, elapsed time.Duration) {
+	logHTTPCall(title, "HTTPError", req, nil, err, elapsed)
+}
+
+// logHTTPCall writes the record for HTTPResponse and HTTPError.
+func logHTTPCall(title string, functionName string, req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	if req == nil && resp != nil {
+		req = resp.Request
+	}
+
+	if req == nil {
+		return
+	}
+
+	logger.Serialize.Lock()
+	keys, patterns := logger.RedactKeys, logger.RedactPatterns
+	logger.Serialize.Unlock()
+
+	target := redactURL(req.URL, keys, patterns)
+
+	rec := Record{Level: LEVEL_INFO, Title: title, Function: functionName, Tag: "Info"}
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+
+	rec.Fields = []Field{
+		{Key: "method", Value: req.Method},
+		{Key: "url", Value: target},
+		{Key: "status", Value: status},
+		Duration("elapsed", elapsed),
+		{Key: "request_headers", Value: redactHeaders(req.Header, keys)},
+	}
+
+	switch {
+	case resp == nil:
+		rec.Level, rec.Tag = LEVEL_ERROR, "ERROR"
+		rec.Err = err
+		if rec.Err == nil {
+			rec.Err = errors.New("no response")
+		}
+	case status >= http.StatusInternalServerError:
+		rec.Level, rec.Tag = LEVEL_ERROR, "ERROR"
+		rec.Err = fmt.Errorf("%d %s", status, http.StatusText(status))
+	}
+
+	if resp != nil {
+		rec.Fields = append(rec.Fields, Field{Key: "response_headers", Value: redactHeaders(resp.Header, keys)})
+	}
+
+	logger.output(3, rec)
+}
+
+// redactHeaders returns a copy of the headers with the credential headers and
+// the headers named by the redact keys masked.
+func redactHeaders(headers http.Header, keys map[string]struct{}) http.Header {
+	redacted := make(http.Header, len(headers))
+	for name, values := range headers {
+		_, masked := keys[strings.ToLower(name)]
+		for _, key := range credentialHeaders {
+			masked = masked || strings.EqualFold(name, key)
+		}
+
+		if masked {
+			redacted[name] = []string{redactedValue}
+			continue
+		}
+
+		redacted[name] = append([]string(nil), values...)
+	}
+
+	return redacted
+}
+
+// redactURL returns the url with the password and the query parameters named by
+// the redact keys masked, and the redact patterns applied.
+func redactURL(u *url.URL, keys map[string]struct{}, patterns []redactPattern) string {
+	if u == nil {
+		return ""
+	}
+
+	// The query is masked in place to keep the order of the parameters.
+	masked := *u
+	if masked.RawQuery != "" && len(keys) > 0 {
+		params := strings.Split(masked.RawQuery, "&")
+		for i, param := range params {
+			name := strings.SplitN(param, "=", 2)[0]
+			if unescaped, err := url.QueryUnescape(name); err == nil {
+				name = unescaped
+			}
+
+			if _, ok := keys[strings.ToLower(name)]; ok {
+				params[i] = strings.SplitN(param, "=", 2)[0] + "=" + redactedValue
+			}
+		}
+		masked.RawQuery = strings.Join(params, "&")
+	}
+
+	text := masked.Redacted()
+	for _, pattern := range patterns {
+		text = pattern.re.ReplaceAllString(text, pattern.replacement)
+	}
+
+	return text
+}
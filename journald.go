@@ -0,0 +1,160 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// journalSocket is the datagram socket of the systemd journal.
+const journalSocket = "/run/systemd/journal/socket"
+
+// StartJournald initializes tracelog to write the specified logging level to the
+// systemd journal using its native protocol. Every record is sent with PRIORITY
+// and MESSAGE plus the title, function, caller and fields as journal fields.
+// Nothing is written to the console since systemd already captures it.
+func StartJournald(logLevel int32) error {
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return err
+	}
+
+	turnOnLogging(0, 0, nil)
+	AddSink(logLevel, &journaldSink{conn: conn, identifier: filepath.Base(os.Args[0])})
+
+	return nil
+}
+
+// SdDaemonEncoder starts every line with the <N> syslog priority prefix that
+// systemd reads from the stdout of a service, so the journal gets the severity
+// of each line without the journal socket. A record with line breaks has the
+// prefix on each line.
+//
+//	log.SetConsoleFormat(log.FormatSdDaemon)
+type SdDaemonEncoder struct {
+	// Encoder writes the line after the prefix, the text encoder when nil.
+	Encoder Encoder
+}
+
+// Encode implements the Encoder interface.
+func (sdDaemonEncoder SdDaemonEncoder) Encode(rec Record) []byte {
+	encoder := sdDaemonEncoder.Encoder
+	if encoder == nil {
+		encoder = TextEncoder{}
+	}
+
+	line := encoder.Encode(rec)
+	prefix := fmt.Sprintf("<%d>", syslogSeverity(rec.Level))
+
+	var buf bytes.Buffer
+	buf.Grow(len(line) + len(prefix))
+
+	for len(line) > 0 {
+		end := bytes.IndexByte(line, '\n') + 1
+		if end == 0 {
+			end = len(line)
+		}
+
+		buf.WriteString(prefix)
+		buf.Write(line[:end])
+		line = line[end:]
+	}
+
+	return buf.Bytes()
+}
+
+// syslogSeverity maps the logging level to the syslog severity used by GELF,
+// the journal and the severity field of the encoders.
+func syslogSeverity(level int32) int {
+	switch level {
+	case LEVEL_ERROR:
+		return 3
+	case LEVEL_WARN:
+		return 4
+	case LEVEL_INFO:
+		return 6
+	}
+
+	return 7
+}
+
+// journaldSink sends records to the journal as native journal entries.
+type journaldSink struct {
+	conn       net.Conn
+	identifier string
+}
+
+// WriteRecord implements the Sink interface.
+func (journaldSink *journaldSink) WriteRecord(rec Record) error {
+	var buf bytes.Buffer
+
+	writeJournalField(&buf, "PRIORITY", fmt.Sprint(syslogSeverity(rec.Level)))
+	writeJournalField(&buf, "MESSAGE", recordText(rec))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", journaldSink.identifier)
+	writeJournalField(&buf, "TITLE", rec.Title)
+	writeJournalField(&buf, "FUNCTION", rec.Function)
+	writeJournalField(&buf, "TAG", rec.Tag)
+
+	if i := strings.LastIndex(rec.Caller, ":"); i >= 0 {
+		writeJournalField(&buf, "CODE_FILE", rec.Caller[:i])
+		writeJournalField(&buf, "CODE_LINE", rec.Caller[i+1:])
+	}
+
+	if rec.Err != nil {
+		writeJournalField(&buf, "ERROR", rec.Err.Error())
+	}
+
+	for _, field := range rec.Fields {
+		writeJournalField(&buf, journalFieldName(field.Key), fmt.Sprint(field.Value))
+	}
+
+	setWriteDeadline(journaldSink.conn)
+
+	_, err := journaldSink.conn.Write(buf.Bytes())
+	return err
+}
+
+// Close closes the connection to the journal.
+func (journaldSink *journaldSink) Close() error {
+	return journaldSink.conn.Close()
+}
+
+// writeJournalField writes a field in the journal's native format. Values with
+// a newline are written with an explicit little endian length.
+func writeJournalField(buf *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", name, value)
+		return
+	}
+
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName converts a field key into a valid journal field name, which
+// is upper case letters, digits and underscores and can't start with an underscore.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+
+	if len(name) == 0 || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		return "F_" + string(name)
+	}
+
+	return string(name)
+}
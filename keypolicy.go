@@ -0,0 +1,127 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// KeyPolicy decides what happens to a field whose key some backends can't
+// take, such as a key with a space, a dot or a quote. A valid key is made of
+// ASCII letters, digits, underscores and dashes and is not empty.
+type KeyPolicy int32
+
+// The key policies.
+const (
+	KeyAllowAny       KeyPolicy = iota // Write the key as it is, the default
+	KeyReplaceInvalid                  // Replace each invalid character with an underscore
+	KeyErrorOnInvalid                  // Drop the field and write an error naming the key
+)
+
+// reportedKeys holds the invalid keys already written as errors, so a bad key
+// in a hot loop is only reported once.
+var reportedKeys sync.Map
+
+// SetKeyPolicy changes what happens to the fields with an invalid key before
+// the record is encoded. It applies to the fields of every record, event and
+// audit record. By default the keys are written as they are.
+func SetKeyPolicy(policy KeyPolicy) {
+	atomic.StoreInt32(&logger.KeyPolicy, int32(policy))
+}
+
+// sanitizeKeys applies the key policy to the fields of the record.
+func (traceLog *traceLog) sanitizeKeys(rec *Record) {
+	policy := KeyPolicy(atomic.LoadInt32(&traceLog.KeyPolicy))
+	if policy == KeyAllowAny || len(rec.Fields) == 0 {
+		return
+	}
+
+	fields, invalid := sanitizeFields(rec.Fields, policy)
+	rec.Fields = fields
+
+	for _, key := range invalid {
+		if _, reported := reportedKeys.LoadOrStore(key, struct{}{}); !reported {
+			internalErrorf(fmt.Errorf("invalid field key %q", key), "SanitizeKeys", "Function[%s] Field Dropped", rec.Function)
+		}
+	}
+}
+
+// sanitizeFields returns the fields with the policy applied and the invalid
+// keys that were dropped. The fields are copied before they are changed since
+// the caller may share them.
+func sanitizeFields(fields []Field, policy KeyPolicy) ([]Field, []string) {
+	var sanitized []Field
+	var invalid []string
+
+	for i, field := range fields {
+		if validKey(field.Key) {
+			if sanitized != nil {
+				sanitized = append(sanitized, field)
+			}
+			continue
+		}
+
+		if sanitized == nil {
+			sanitized = append(make([]Field, 0, len(fields)), fields[:i]...)
+		}
+
+		if policy == KeyErrorOnInvalid {
+			invalid = append(invalid, field.Key)
+			continue
+		}
+
+		sanitized = append(sanitized, Field{Key: replaceInvalidKey(field.Key), Value: field.Value})
+	}
+
+	if sanitized == nil {
+		return fields, nil
+	}
+
+	return sanitized, invalid
+}
+
+// validKey reports if the key only has ASCII letters, digits, underscores and
+// dashes and is not empty.
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if !validKeyByte(key[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validKeyByte reports if the byte can be part of a key.
+func validKeyByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_' || b == '-'
+}
+
+// replaceInvalidKey replaces each invalid character of the key with an
+// underscore, so the same key always gives the same result. An empty key
+// becomes a single underscore.
+func replaceInvalidKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+
+	replaced := make([]byte, 0, len(key))
+	for _, r := range key {
+		if r < 0x80 && validKeyByte(byte(r)) {
+			replaced = append(replaced, byte(r))
+			continue
+		}
+
+		replaced = append(replaced, '_')
+	}
+
+	return string(replaced)
+}
@@ -0,0 +1,72 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// levels lists the logging levels from the most verbose to the most severe.
+var levels = []int32{LEVEL_TRACE, LEVEL_INFO, LEVEL_WARN, LEVEL_ERROR}
+
+// maskTests covers every combination of the four level flags with the levels
+// each mask enables, in the order of levels. The least severe flag in the mask
+// decides, so the more severe flags added to it change nothing.
+var maskTests = []struct {
+	mask    int32
+	enabled [4]bool
+}{
+	{0, [4]bool{false, false, false, false}},
+	{LEVEL_TRACE, [4]bool{true, true, true, true}},
+	{LEVEL_INFO, [4]bool{false, true, true, true}},
+	{LEVEL_TRACE | LEVEL_INFO, [4]bool{true, true, true, true}},
+	{LEVEL_WARN, [4]bool{false, false, true, true}},
+	{LEVEL_TRACE | LEVEL_WARN, [4]bool{true, true, true, true}},
+	{LEVEL_INFO | LEVEL_WARN, [4]bool{false, true, true, true}},
+	{LEVEL_TRACE | LEVEL_INFO | LEVEL_WARN, [4]bool{true, true, true, true}},
+	{LEVEL_ERROR, [4]bool{false, false, false, true}},
+	{LEVEL_TRACE | LEVEL_ERROR, [4]bool{true, true, true, true}},
+	{LEVEL_INFO | LEVEL_ERROR, [4]bool{false, true, true, true}},
+	{LEVEL_TRACE | LEVEL_INFO | LEVEL_ERROR, [4]bool{true, true, true, true}},
+	{LEVEL_WARN | LEVEL_ERROR, [4]bool{false, false, true, true}},
+	{LEVEL_TRACE | LEVEL_WARN | LEVEL_ERROR, [4]bool{true, true, true, true}},
+	{LEVEL_INFO | LEVEL_WARN | LEVEL_ERROR, [4]bool{false, true, true, true}},
+	{LEVEL_TRACE | LEVEL_INFO | LEVEL_WARN | LEVEL_ERROR, [4]bool{true, true, true, true}},
+}
+
+// TestLevelEnabled checks the levels enabled by every mask.
+func TestLevelEnabled(t *testing.T) {
+	for _, tt := range maskTests {
+		for i, level := range levels {
+			if got := levelEnabled(tt.mask, level); got != tt.enabled[i] {
+				t.Errorf("levelEnabled(%s, %s) = %v, want %v", LevelMaskString(tt.mask), LevelName(level), got, tt.enabled[i])
+			}
+		}
+	}
+}
+
+// TestLevelHandles checks the destinations every mask turns on. Trace, Info and
+// Warning go to the standard handle and Error to the error handle.
+func TestLevelHandles(t *testing.T) {
+	var std, errs bytes.Buffer
+
+	for _, tt := range maskTests {
+		traceHandle, infoHandle, warnHandle, errorHandle := levelHandles(tt.mask, &std, &errs)
+		handles := []interface{}{traceHandle, infoHandle, warnHandle, errorHandle}
+		want := []interface{}{&std, &std, &std, &errs}
+
+		for i, handle := range handles {
+			if !tt.enabled[i] {
+				want[i] = ioutil.Discard
+			}
+
+			if handle != want[i] {
+				t.Errorf("mask %s : %s handle = %T, want %T", LevelMaskString(tt.mask), LevelName(levels[i]), handle, want[i])
+			}
+		}
+	}
+}
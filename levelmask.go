@@ -0,0 +1,62 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseLevelMask parses a list of level names separated by commas, plus signs
+// or pipes, such as "warn,error", into a level mask. The names are trace, info,
+// warn or warning and error in any case. An empty string or "none" is 0.
+//
+// A mask is treated the way Start treats a single level: the least severe level
+// in the mask enables itself and every level more severe than it. The mask for
+// "info,error" therefore also writes warnings, and "warn,error" behaves the
+// same as "warn".
+func ParseLevelMask(s string) (int32, error) {
+	var mask int32
+
+	names := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '+' || r == '|'
+	})
+
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "trace":
+			mask |= LEVEL_TRACE
+		case "info":
+			mask |= LEVEL_INFO
+		case "warn", "warning":
+			mask |= LEVEL_WARN
+		case "error":
+			mask |= LEVEL_ERROR
+		case "none", "":
+		default:
+			return 0, fmt.Errorf("unknown level %q in %q", strings.TrimSpace(name), s)
+		}
+	}
+
+	return mask, nil
+}
+
+// LevelMaskString returns the names of the levels set in the mask separated by
+// commas, such as "WARNING,ERROR". A mask of 0 is "NONE". The result can be
+// parsed back with ParseLevelMask.
+func LevelMaskString(mask int32) string {
+	var names []string
+	for _, level := range []int32{LEVEL_TRACE, LEVEL_INFO, LEVEL_WARN, LEVEL_ERROR} {
+		if mask&level != 0 {
+			names = append(names, LevelName(level))
+		}
+	}
+
+	if len(names) == 0 {
+		return "NONE"
+	}
+
+	return strings.Join(names, ",")
+}
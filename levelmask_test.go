@@ -0,0 +1,74 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+// TestParseLevelMask checks the names, separators and errors the parser accepts.
+func TestParseLevelMask(t *testing.T) {
+	tests := []struct {
+		s    string
+		mask int32
+		ok   bool
+	}{
+		{"", 0, true},
+		{"none", 0, true},
+		{"trace", LEVEL_TRACE, true},
+		{"INFO", LEVEL_INFO, true},
+		{"warn,error", LEVEL_WARN | LEVEL_ERROR, true},
+		{"Warning+Error", LEVEL_WARN | LEVEL_ERROR, true},
+		{" info | error ", LEVEL_INFO | LEVEL_ERROR, true},
+		{"trace,info,warn,error", LEVEL_TRACE | LEVEL_INFO | LEVEL_WARN | LEVEL_ERROR, true},
+		{"info,debug", 0, false},
+	}
+
+	for _, tt := range tests {
+		mask, err := ParseLevelMask(tt.s)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("ParseLevelMask(%q) error = %v, want ok %v", tt.s, err, tt.ok)
+			continue
+		}
+
+		if mask != tt.mask {
+			t.Errorf("ParseLevelMask(%q) = %d, want %d", tt.s, mask, tt.mask)
+		}
+	}
+}
+
+// TestLevelMaskString checks that every mask is written as names that parse
+// back to the same mask.
+func TestLevelMaskString(t *testing.T) {
+	if s := LevelMaskString(LEVEL_WARN | LEVEL_ERROR); s != "WARNING,ERROR" {
+		t.Errorf("LevelMaskString(warn,error) = %q, want %q", s, "WARNING,ERROR")
+	}
+
+	for _, tt := range maskTests {
+		s := LevelMaskString(tt.mask)
+		mask, err := ParseLevelMask(s)
+		if err != nil || mask != tt.mask {
+			t.Errorf("ParseLevelMask(LevelMaskString(%d)) = %d, %v, want %d", tt.mask, mask, err, tt.mask)
+		}
+	}
+}
+
+// TestStartLevelMask checks the destinations Start turns on for every mask. The
+// least severe level in the mask enables itself and every level more severe.
+func TestStartLevelMask(t *testing.T) {
+	defer turnOnLogging(0, 0, nil)
+
+	for _, tt := range maskTests {
+		turnOnLogging(tt.mask, 0, nil)
+
+		for i, level := range levels {
+			enabled := logger.handle(level) != ioutil.Discard
+			if enabled != tt.enabled[i] {
+				t.Errorf("Start(%s) : %s enabled = %v, want %v", LevelMaskString(tt.mask), LevelName(level), enabled, tt.enabled[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// levelWatchInterval is how often the level file is checked for changes.
+const levelWatchInterval = time.Second
+
+// WatchLevelFile checks the file every second and applies its contents as the
+// logging level whenever they change, so operators can change the verbosity by
+// editing the file. The file holds a level mask such as "warn,error" or a number
+// such as 1. A missing file leaves the level alone. Stop ends the watcher and
+// watching another file replaces it.
+func WatchLevelFile(path string) {
+	logger.Serialize.Lock()
+	logger.stopLevelWatch()
+	stop := make(chan struct{})
+	logger.LevelWatchStop = stop
+	logger.Serialize.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(levelWatchInterval)
+		defer ticker.Stop()
+
+		var applied string
+		for {
+			applied = applyLevelFile(path, applied)
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// applyLevelFile sets the logging level from the file when its contents differ
+// from the contents applied last. It returns the contents now in effect.
+func applyLevelFile(path string, applied string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return applied
+	}
+
+	content := strings.TrimSpace(string(data))
+	if content == applied {
+		return applied
+	}
+
+	logLevel, err := parseLevel(content)
+	if err != nil {
+		Warning("main", "WatchLevelFile", "Invalid Level File[%s] : %s", path, err)
+		return content
+	}
+
+	SetLogLevel(logLevel)
+	Info("main", "WatchLevelFile", "Level Changed File[%s] Level[%s]", path, LevelMaskString(logLevel))
+	return content
+}
+
+// parseLevel parses a numeric level or a level mask.
+func parseLevel(s string) (int32, error) {
+	if n, err := strconv.ParseInt(s, 10, 32); err == nil {
+		return int32(n), nil
+	}
+
+	return ParseLevelMask(s)
+}
+
+// stopLevelWatch ends the level file watcher. The Serialize lock must be held by
+// the caller.
+func (traceLog *traceLog) stopLevelWatch() {
+	if traceLog.LevelWatchStop != nil {
+		close(traceLog.LevelWatchStop)
+		traceLog.LevelWatchStop = nil
+	}
+}
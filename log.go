@@ -61,23 +61,36 @@
 package log
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/smtp"
+	"net/textproto"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"text/template"
 	"time"
+	"unicode/utf8"
 )
 
 const systemAlertSubject = "TraceLog Exception"
 
+// The logging levels are bit flags. A level turns on its own destination and the
+// destinations of every more severe level. When flags are combined the most verbose
+// flag decides, so LEVEL_INFO|LEVEL_ERROR logs the same as LEVEL_INFO. A level of 0
+// turns every destination off.
 const (
 	LEVEL_TRACE int32 = 1 // Log everything
 	LEVEL_INFO  int32 = 2 // Log Info, Warnings and Errors
@@ -96,21 +109,100 @@ type emailConfiguration struct {
 	Template *template.Template
 }
 
+// settings contains the configuration of the logging system that is kept when
+// the logging system is started again, such as the format and redaction rules.
+type settings struct {
+	EmailConfiguration *emailConfiguration
+	NilErrorPolicy     int32
+	Encoder            Encoder
+	ConsoleEncoder     Encoder
+	FileEncoder        Encoder
+	LineEnding         string
+	TitleDelimiter     string
+	WriteTimeout       int64
+	PanicStackDepth    int
+	PanicFrameFilter   func(frame runtime.Frame) bool
+	Tags               map[string]string
+	Prefixes           map[int32]string
+	MaxLineLength      int
+	IncludeFuncName    bool
+	RateLimiter        *callerRateLimiter
+	RedactKeys         map[string]struct{}
+	RedactPatterns     []redactPattern
+	OverflowPolicy     int32
+	EmailRetries       int
+	EmailBackoff       time.Duration
+	EmailHELO          string
+	EmailMaxBody       int
+	EmailOnLevel       int32
+	Webhook            *webhookConfiguration
+	ShutdownEmail      bool
+	AlsoStdout         bool
+	LevelFlags         map[int32]int
+	Filters            []func(rec *Record) bool
+	IncludeTemplate    bool
+	IncludeArgs        bool
+	MailSender         MailSender
+	MaxNameLength      int
+	FailFast           bool
+	KeyPolicy          int32
+	LineInterceptor    func(level int32, line string)
+}
+
 // traceLog provides support to write to log files.
 type traceLog struct {
-	LogLevel           int32
-	EmailConfiguration *emailConfiguration
-	Trace              *log.Logger
-	Info               *log.Logger
-	Warning            *log.Logger
-	Error              *log.Logger
-	File               *log.Logger
-	LogFile            *os.File
+	settings
+	LogLevel          int32
+	Paused            int32
+	Trace             io.Writer
+	Info              io.Writer
+	Warning           io.Writer
+	Error             io.Writer
+	TraceFile         io.Writer
+	InfoFile          io.Writer
+	WarningFile       io.Writer
+	ErrorFile         io.Writer
+	ConsoleLevel      int32
+	FileLevel         int32
+	FileHandle        io.Writer
+	BoostTimer        *time.Timer
+	BoostConsoleLevel int32
+	BoostFileLevel    int32
+	File              *log.Logger
+	LogFile           *os.File
+	LevelFiles        map[int32]*os.File
+	LockFile          *os.File
+	Rolling           *rollingFile
+	Compress          bool
+	GzipWriter        *gzip.Writer
+	FileBuffer        *bufio.Writer
+	FlushStop         chan struct{}
+	LevelWatchStop    chan struct{}
+	SignalToggle      *signalToggle
+	ErrorSummary      *errorSummary
+	BaseFilePath      string
+	DaysToKeep        int
+	Destinations      map[io.Writer]*destination
+	EventWriter       io.Writer
+	SuppressedLines   int64
+	Counts            [4]int64
+	Sinks             []sinkEntry
+	SinkLevel         int32
+	Ring              *ringBuffer
+	Fallback          io.Writer
+	Async             *asyncWriter
+	DroppedLines      int64
+	UnsentAlertWarned int32
+	Serialize         sync.Mutex
+	EmailThrottle     emailThrottle
 }
 
 // log maintains a pointer to a singleton for the logging system.
 var logger traceLog
 
+// fileLocation is the time zone used to name log directories and files.
+var fileLocation = time.UTC
+
 // Called to init the logging system.
 func init() {
 	log.SetPrefix("TRACE: ")
@@ -118,55 +210,364 @@ func init() {
 }
 
 // Start initializes tracelog and only displays the specified logging level.
+// Settings such as the format, tags and redaction rules are kept when the
+// logging system is stopped and started again.
 func Start(logLevel int32) {
-	turnOnLogging(logLevel, nil)
+	turnOnLogging(logLevel, 0, nil)
 }
 
 // StartFile initializes tracelog and only displays the specified logging level
-// and creates a file to capture writes.
+// and creates a file to capture writes. Date directories older than daysToKeep
+// are removed, a daysToKeep of 0 or less keeps every directory.
 func StartFile(logLevel int32, baseFilePath string, daysToKeep int) {
+	StartFileSplit(logLevel, logLevel, baseFilePath, daysToKeep)
+}
+
+// StartFileSplit initializes tracelog with separate logging levels for the console
+// and the file. This allows verbose writes to the file while the console only
+// displays the more important messages.
+func StartFileSplit(consoleLevel int32, fileLevel int32, baseFilePath string, daysToKeep int) {
+	startFile(consoleLevel, fileLevel, baseFilePath, daysToKeep, fileOptions{})
+}
+
+// fileOptions selects how the log file is written.
+type fileOptions struct {
+	rolling  *rollingFile // Number the files within the day and roll on size
+	compress bool         // Write the file through gzip
+}
+
+// startFile creates the log file and turns the logging on. A rolling file is
+// numbered within the day and replaced when the day or size limit is reached.
+func startFile(consoleLevel int32, fileLevel int32, baseFilePath string, daysToKeep int, options fileOptions) {
 	baseFilePath = strings.TrimRight(baseFilePath, "/")
-	currentDate := time.Now().UTC()
-	dateDirectory := time.Now().UTC().Format("2006-01-02")
-	dateFile := currentDate.Format("2006-01-02T15-04-05")
 
-	filePath := fmt.Sprintf("%s/%s/", baseFilePath, dateDirectory)
-	fileName := strings.Replace(fmt.Sprintf("%s.txt", dateFile), " ", "-", -1)
+	lockf, err := fileLock(baseFilePath)
+	if err != nil {
+		log.Fatalf("main : Start : %s\n", err)
+	}
+
+	ext := fileExtension
+	if options.compress {
+		ext += ".gz"
+	}
 
-	err := os.MkdirAll(filePath, os.ModePerm)
+	var logf *os.File
+	if options.rolling != nil {
+		logf, err = options.rolling.create(baseFilePath, ext)
+	} else {
+		logf, err = createLogFile(baseFilePath, ext)
+	}
 	if err != nil {
-		log.Fatalf("main : Start : Failed to Create log directory : %s : %s\n", filePath, err)
+		log.Fatalf("main : Start : %s\n", err)
 	}
 
-	logf, err := os.Create(fmt.Sprintf("%s%s", filePath, fileName))
+	levelFiles, err := createLevelFiles(baseFilePath)
 	if err != nil {
-		log.Fatalf("main : Start : Failed to Create log file : %s : %s\n", fileName, err)
+		log.Fatalf("main : Start : %s\n", err)
 	}
 
 	// Turn the logging on
-	turnOnLogging(logLevel, logf)
+	turnOnLogging(consoleLevel, fileLevel, logf)
+	logger.LogFile = logf
+	logger.LevelFiles = levelFiles
+	logger.LockFile = lockf
+	logger.Rolling = options.rolling
+	logger.Compress = options.compress
+	if options.compress {
+		logger.GzipWriter = gzip.NewWriter(logf)
+		logger.FileHandle = logger.GzipWriter
+		logger.startFlusher(gzipFlushInterval)
+	}
+	logger.setHandles(consoleLevel, fileLevel)
+	logger.writeFileHeader(nil)
+	logger.BaseFilePath = baseFilePath
+	logger.DaysToKeep = daysToKeep
 
 	// Cleanup any existing directories
-	logger.LogDirectoryCleanup(baseFilePath, daysToKeep)
+	logger.applyRetention(baseFilePath, daysToKeep)
+}
+
+// SetFileLocation sets the time zone used to name the date directories and log
+// files and to decide which directories the cleanup removes. Call it before
+// StartFile. The default is UTC, passing nil restores it.
+func SetFileLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	fileLocation = loc
+}
+
+// createLogFile creates the date directory and a new log file with the extension
+// to capture writes.
+func createLogFile(baseFilePath string, ext string) (*os.File, error) {
+	currentDate := time.Now().In(fileLocation)
+	dateDirectory := currentDate.Format("2006-01-02")
+	dateFile := currentDate.Format("2006-01-02T15-04-05")
+
+	// The pid keeps processes sharing the directory from opening the same file.
+	filePath := fmt.Sprintf("%s/%s/", baseFilePath, dateDirectory)
+	fileName := strings.Replace(fmt.Sprintf("%s-%d", dateFile, os.Getpid()), " ", "-", -1)
+
+	// Networked file systems can fail for a moment, so retry with a backoff.
+	backoff := fileCreateBackoff
+	for retry := 0; ; retry++ {
+		err := os.MkdirAll(filePath, os.ModePerm)
+		if err == nil {
+			break
+		}
+
+		if retry >= fileCreateRetries || os.IsPermission(err) {
+			return nil, fmt.Errorf("Failed to Create log directory : %s : %s", filePath, err)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	// Never truncate an existing file. If the name is taken add a random suffix.
+	name := fmt.Sprintf("%s.%s", fileName, ext)
+	backoff = fileCreateBackoff
+	for attempt, retry := 0, 0; ; {
+		logf, err := os.OpenFile(fmt.Sprintf("%s%s", filePath, name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			return logf, nil
+		}
+
+		switch {
+		case os.IsExist(err) && attempt < maxFileNameAttempts:
+			attempt++
+			name = fmt.Sprintf("%s-%s.%s", fileName, randomSuffix(), ext)
+
+		case !os.IsExist(err) && !os.IsPermission(err) && retry < fileCreateRetries:
+			retry++
+			time.Sleep(backoff)
+			backoff *= 2
+
+		default:
+			return nil, fmt.Errorf("Failed to Create log file : %s : %s", name, err)
+		}
+	}
+}
+
+// maxFileNameAttempts limits how many random suffixes are tried for a log file name.
+const maxFileNameAttempts = 5
+
+// fileCreateRetries limits how many times a failed create of the log directory
+// or file is retried. The first retry waits fileCreateBackoff and every retry
+// after that waits twice as long.
+const (
+	fileCreateRetries = 3
+	fileCreateBackoff = 100 * time.Millisecond
+)
+
+// randomSuffix returns a short random hex string used to make file names unique.
+func randomSuffix() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(b)
 }
 
-// Stop will release resources and shutdown all processing.
+// Stop will release resources and shutdown all processing. The shutdown runs
+// in a fixed order so no line is lost and nothing is written to a closed file:
+//
+//  1. The error summary is written and the level watcher, signal toggle and
+//     level boost are stopped so nothing changes the destinations.
+//  2. The asynchronous queue is drained, later lines are written directly.
+//  3. The alert emails and webhooks in flight are given time to finish.
+//  4. A write abandoned after the write timeout is given time to return. The
+//     file and the sinks are then detached while holding the lock. Lines
+//     written from then on only go to the console.
+//  5. The file buffer is flushed and the sinks, the files and the lock file are
+//     closed. Files still used by an abandoned write are left open.
 func Stop() error {
+	if errs := stopLogging(); len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// stopLogging releases the resources and returns every error it ran into.
+func stopLogging() []error {
 	Started("main", "Stop")
 
-	var err error
+	logger.writeErrorSummary()
+
+	logger.Serialize.Lock()
+	logger.stopLevelWatch()
+	logger.removeSignalToggle()
+	if logger.BoostTimer != nil {
+		logger.BoostTimer.Stop()
+		logger.BoostTimer = nil
+	}
+	logger.Serialize.Unlock()
+
+	var errs []error
+	if err := logger.stopAsync(defaultDrainTimeout); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := waitForAlerts(defaultDrainTimeout); err != nil {
+		errs = append(errs, err)
+	}
+
 	if logger.LogFile != nil {
 		Trace("main", "Stop", "Closing File")
-		err = logger.LogFile.Close()
 	}
 
+	// Detach everything while holding the lock, every write holds it too, so
+	// once it's released nothing is writing to the files or the sinks.
+	logger.Serialize.Lock()
+
+	files, detachErrs := logger.detachFile()
+	errs = append(errs, detachErrs...)
+
+	sinks := logger.Sinks
+	atomic.AddInt64(&stops, 1)
+	atomic.AddInt64(&starts, 1)
+
+	logger.FileHandle = nil
+	logger.Sinks = nil
+	logger.Ring = nil
+	atomic.StoreInt32(&logger.SinkLevel, 0)
+	logger.setHandles(logger.ConsoleLevel, 0)
+	logger.Serialize.Unlock()
+
+	// Sinks go first, they may have their own queues to send.
+	for _, entry := range sinks {
+		if closer, ok := entry.sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	errs = append(errs, files.close()...)
+
 	Completed("main", "Stop")
-	return err
+	return errs
+}
+
+// starts counts the times the destinations were replaced by a start or a stop,
+// and stops the times Stop closed them. Restore uses them to tell if the
+// destinations of a snapshot are still open.
+var starts, stops int64
+
+// detachedFiles are the files of the logging system detached by detachFile,
+// closed once the Serialize lock is released.
+type detachedFiles struct {
+	logf       *os.File
+	gz         *gzip.Writer
+	levelFiles map[int32]*os.File
+	lockf      *os.File
+}
+
+// detachFile detaches the log file, the level files and the lock file. A file
+// still used by an abandoned write is left open rather than closed under it,
+// and ErrWriteTimeout is among the errors returned. The handles must be rebuilt
+// by the caller.
+// The Serialize lock must be held by the caller, it's released while waiting
+// for an abandoned write.
+func (traceLog *traceLog) detachFile() (detachedFiles, []error) {
+	var errs []error
+	idle := traceLog.waitForFile(defaultDrainTimeout)
+
+	if err := traceLog.stopFlusher(); err != nil {
+		errs = append(errs, err)
+	}
+
+	files := detachedFiles{
+		logf:       traceLog.LogFile,
+		gz:         traceLog.GzipWriter,
+		levelFiles: traceLog.LevelFiles,
+		lockf:      traceLog.LockFile,
+	}
+
+	if !idle {
+		errs = append(errs, ErrWriteTimeout)
+		files.logf, files.gz, files.levelFiles = nil, nil, nil
+	}
+
+	traceLog.FileBuffer = nil
+	traceLog.GzipWriter = nil
+	traceLog.LogFile = nil
+	traceLog.LevelFiles = nil
+	traceLog.LockFile = nil
+	traceLog.Rolling = nil
+
+	return files, errs
+}
+
+// close closes the detached files and returns every error it ran into.
+func (files detachedFiles) close() []error {
+	var errs []error
+
+	// Closing the gzip writer writes the end of the compressed stream.
+	if files.gz != nil {
+		if err := files.gz.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if files.logf != nil {
+		if err := files.logf.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := closeLevelFiles(files.levelFiles); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Closing the lock file releases the lock.
+	if files.lockf != nil {
+		files.lockf.Close()
+	}
+
+	return errs
 }
 
-// ConfigureEmail configures the email system for use.
+// ConfigureEmail configures the email system for use. It is safe to call while
+// alerts are being sent, such as to rotate the credentials. An email already
+// being sent finishes with the previous configuration.
 func ConfigureEmail(host string, port int, userName string, password string, to []string) {
-	logger.EmailConfiguration = &emailConfiguration{
+	configuration := newEmailConfiguration(host, port, userName, password, to)
+
+	logger.Serialize.Lock()
+	logger.EmailConfiguration = configuration
+	logger.Serialize.Unlock()
+}
+
+// UpdateEmailCredentials replaces the user name and password used to send the
+// alert emails, for servers whose credentials are rotated while the program
+// runs. Emails being sent keep the credentials they started with. An error is
+// returned if ConfigureEmail has not been called.
+func UpdateEmailCredentials(userName string, password string) error {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	if logger.EmailConfiguration == nil {
+		return errors.New("email is not configured")
+	}
+
+	// The configuration is copied rather than changed so a send that has
+	// already taken it never sees half of the new credentials.
+	configuration := *logger.EmailConfiguration
+	configuration.UserName = userName
+	configuration.Password = password
+	configuration.Auth = smtp.PlainAuth("", userName, password, configuration.Host)
+
+	logger.EmailConfiguration = &configuration
+	return nil
+}
+
+// newEmailConfiguration returns the email configuration for the server and recipients.
+func newEmailConfiguration(host string, port int, userName string, password string, to []string) *emailConfiguration {
+	return &emailConfiguration{
 		Host:     host,
 		Port:     port,
 		UserName: userName,
@@ -177,38 +578,120 @@ func ConfigureEmail(host string, port int, userName string, password string, to
 	}
 }
 
+// emailParameters contains the values available to the email template.
+type emailParameters struct {
+	From      string
+	To        string
+	Subject   string
+	Message   string
+	Severity  string
+	Host      string
+	Timestamp time.Time
+}
+
 // SendEmailException will send an email along with the exception.
 func SendEmailException(subject string, message string, a ...interface{}) error {
+	return sendEmail("ERROR", subject, fmt.Sprintf(message, a...))
+}
+
+// sendEmail renders the email template for the alert and sends it.
+func sendEmail(severity string, subject string, message string) error {
 	var err error
 	defer logger.CatchPanic(&err, "SendEmailException")
 
-	if logger.EmailConfiguration == nil {
-		return err
+	// Take the settings once so a concurrent ConfigureEmail can't change them
+	// part way through.
+	logger.Serialize.Lock()
+	configuration := logger.EmailConfiguration
+	retries := logger.EmailRetries
+	backoff := logger.EmailBackoff
+	helo := logger.EmailHELO
+	maxBody := logger.EmailMaxBody
+	sender := logger.MailSender
+	logger.Serialize.Unlock()
+
+	if configuration == nil {
+		if sender == nil {
+			return err
+		}
+
+		// A sender set for tests works without ConfigureEmail.
+		configuration = newEmailConfiguration("", 0, "", "", nil)
+	}
+
+	if sender == nil {
+		sender = smtpSender{configuration: configuration, helo: helo}
 	}
 
-	parameters := struct {
-		From    string
-		To      string
-		Subject string
-		Message string
-	}{
-		logger.EmailConfiguration.UserName,
-		strings.Join([]string(logger.EmailConfiguration.To), ","),
-		subject,
-		fmt.Sprintf(message, a...),
+	// Relays reject huge emails, such as ones carrying a long stack.
+	message = truncateMessage(message, maxBody)
+
+	host, _ := os.Hostname()
+
+	parameters := emailParameters{
+		From:      configuration.UserName,
+		To:        strings.Join([]string(configuration.To), ","),
+		Subject:   subject,
+		Message:   message,
+		Severity:  severity,
+		Host:      host,
+		Timestamp: time.Now().UTC(),
 	}
 
 	var emailMessage bytes.Buffer
-	logger.EmailConfiguration.Template.Execute(&emailMessage, &parameters)
+	configuration.Template.Execute(&emailMessage, &parameters)
 
-	err = smtp.SendMail(fmt.Sprintf("%s:%d",
-		logger.EmailConfiguration.Host, logger.EmailConfiguration.Port),
-		logger.EmailConfiguration.Auth,
-		logger.EmailConfiguration.UserName,
-		logger.EmailConfiguration.To,
-		emailMessage.Bytes())
+	for attempt := 0; ; attempt++ {
+		err = sender.SendMail(configuration.UserName, configuration.To, emailMessage.Bytes())
 
-	return err
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= retries || !isTransientEmailError(err) {
+			internalErrorf(err, "SendEmailException", "Sending Email Subject[%s] Attempts[%d]", subject, attempt+1)
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// SetMaxEmailBodyBytes limits the message of an email to n bytes so relays don't
+// reject an alert carrying an enormous stack or message. A longer message is cut
+// and ends with "... (truncated)". A limit of 0 or less sends the whole message,
+// which is the default.
+func SetMaxEmailBodyBytes(n int) {
+	logger.Serialize.Lock()
+	logger.EmailMaxBody = n
+	logger.Serialize.Unlock()
+}
+
+// SetEmailRetries configures how many times a failed email is retried. Only
+// transient failures are retried, waiting backoff and doubling it each attempt.
+func SetEmailRetries(n int, backoff time.Duration) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	logger.EmailRetries = n
+	logger.EmailBackoff = backoff
+}
+
+// isTransientEmailError reports if the error is worth retrying. Network errors
+// and 4xx replies are temporary, 5xx replies are permanent.
+func isTransientEmailError(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF)
 }
 
 // LogLevel returns the configured logging level.
@@ -216,79 +699,769 @@ func LogLevel() int32 {
 	return atomic.LoadInt32(&logger.LogLevel)
 }
 
-// turnOnLogging configures the logging writers.
-func turnOnLogging(logLevel int32, fileHandle io.Writer) {
-	traceHandle := ioutil.Discard
-	infoHandle := ioutil.Discard
-	warnHandle := ioutil.Discard
-	errorHandle := ioutil.Discard
+// LevelCount returns the number of log calls made at the level since Start,
+// whether or not the level's destination is enabled.
+func LevelCount(level int32) int64 {
+	index := levelIndex(level)
+	if index < 0 {
+		return 0
+	}
+
+	return atomic.LoadInt64(&logger.Counts[index])
+}
+
+// ExitCodeFromLog returns 1 if any error was logged since Start, otherwise 0.
+// Command line tools can pass it to os.Exit so failures are visible to scripts.
+//	defer func() { log.Stop(); os.Exit(log.ExitCodeFromLog()) }()
+func ExitCodeFromLog() int {
+	if LevelCount(LEVEL_ERROR) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// levelIndex returns the position of the level in the per level counters.
+func levelIndex(level int32) int {
+	switch level {
+	case LEVEL_TRACE:
+		return 0
+	case LEVEL_INFO:
+		return 1
+	case LEVEL_WARN:
+		return 2
+	case LEVEL_ERROR:
+		return 3
+	}
+
+	return -1
+}
+
+// SetLogLevel changes the logging level of the console and the file without
+// restarting the logging system.
+func SetLogLevel(logLevel int32) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	// An explicit level replaces any boost in progress.
+	if logger.BoostTimer != nil {
+		logger.BoostTimer.Stop()
+		logger.BoostTimer = nil
+	}
+
+	logger.setHandles(logLevel, logLevel)
+}
+
+// SetFileLevels changes which levels are written to the file while the console
+// keeps its level. Use LEVEL_WARN to persist only warnings and errors and keep
+// the trace and info lines on the console. A level of 0 writes nothing to the
+// file.
+func SetFileLevels(logLevel int32) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	// Keep the new file level when a boost in progress ends.
+	if logger.BoostTimer != nil {
+		logger.BoostFileLevel = logLevel
+	}
+
+	logger.setHandles(logger.ConsoleLevel, logLevel)
+}
+
+// BoostLevel changes the logging level for the duration and then restores the
+// level that was in effect before the boost. Boosting again while a boost is
+// active replaces the level and restarts the duration.
+func BoostLevel(logLevel int32, d time.Duration) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	if logger.BoostTimer == nil {
+		logger.BoostConsoleLevel = logger.ConsoleLevel
+		logger.BoostFileLevel = logger.FileLevel
+	} else {
+		logger.BoostTimer.Stop()
+	}
+
+	logger.setHandles(logLevel, logLevel)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		logger.Serialize.Lock()
+		defer logger.Serialize.Unlock()
+
+		// A later boost or SetLogLevel call has taken over.
+		if logger.BoostTimer != timer {
+			return
+		}
+
+		logger.BoostTimer = nil
+		logger.setHandles(logger.BoostConsoleLevel, logger.BoostFileLevel)
+	})
+	logger.BoostTimer = timer
+}
+
+// turnOnLogging configures the logging writers. The console and the file
+// each receive the destinations enabled by their own logging level.
+func turnOnLogging(consoleLevel int32, fileLevel int32, fileHandle io.Writer) {
+	// The settings and the email throttle configuration survive a restart, only
+	// the destinations are replaced.
+	interval, leadingEdge := logger.EmailThrottle.interval, logger.EmailThrottle.leadingEdge
+
+	// The ring buffer is kept when starting again without Stop, so the lines it
+	// retains can be replayed into the new destinations.
+	ring := logger.Ring
+
+	// The asynchronous queue, the level watcher and the signal toggle keep
+	// running for the new destinations. The flusher and the level boost belong
+	// to the previous start and are stopped so their goroutines don't leak.
+	logger.Serialize.Lock()
+	logger.stopFlusher()
+	if logger.BoostTimer != nil {
+		logger.BoostTimer.Stop()
+	}
+	async, levelWatchStop, signalToggle := logger.Async, logger.LevelWatchStop, logger.SignalToggle
+	logger.Serialize.Unlock()
+
+	logger = traceLog{
+		settings:       logger.settings,
+		FileHandle:     fileHandle,
+		Async:          async,
+		LevelWatchStop: levelWatchStop,
+		SignalToggle:   signalToggle,
+	}
+
+	logger.EmailThrottle.interval = interval
+	logger.EmailThrottle.leadingEdge = leadingEdge
+	atomic.AddInt64(&starts, 1)
+
+	if ring != nil {
+		logger.Ring = ring
+		logger.Sinks = []sinkEntry{{logLevel: ring.logLevel, sink: ring, busy: new(int32)}}
+		logger.SinkLevel = ring.logLevel
+	}
+
+	logger.setHandles(consoleLevel, fileLevel)
+}
+
+// setHandles builds the destination handles for the console and file levels.
+func (traceLog *traceLog) setHandles(consoleLevel int32, fileLevel int32) {
+	// With AlsoStdout every line written to the file is also written to the
+	// console, so the container runtime still captures it.
+	stdLevel := consoleLevel
+	if traceLog.AlsoStdout {
+		stdLevel |= fileLevel
+	}
+
+	// The levels share a destination for each file and console, so a write
+	// abandoned on one level holds up the others writing to the same place.
+	// Only the destinations in use are kept.
+	destinations := make(map[io.Writer]*destination)
+	stdout := traceLog.destination(destinations, os.Stdout, os.Stdout, false)
+	stderr := traceLog.destination(destinations, os.Stderr, os.Stderr, false)
+
+	traceHandle, infoHandle, warnHandle, errorHandle := levelHandles(stdLevel, stdout, stderr)
+	traceFile, infoFile, warnFile, errorFile := levelHandles(0, nil, nil)
+
+	if traceLog.FileHandle != nil {
+		var fileHandle io.Writer = traceLog.FileHandle
+		if traceLog.Rolling != nil {
+			fileHandle = &rollingCounter{writer: fileHandle, rolling: traceLog.Rolling}
+		}
+		fileHandle = traceLog.destination(destinations, traceLog.FileHandle, fileHandle, true)
+
+		traceFile, infoFile, warnFile, errorFile = levelHandles(fileLevel, fileHandle, fileHandle)
+		traceFile = traceLog.levelFile(destinations, LEVEL_TRACE, traceFile)
+		infoFile = traceLog.levelFile(destinations, LEVEL_INFO, infoFile)
+		warnFile = traceLog.levelFile(destinations, LEVEL_WARN, warnFile)
+		errorFile = traceLog.levelFile(destinations, LEVEL_ERROR, errorFile)
+
+		// With an encoder per destination the file gets its own lines,
+		// otherwise a single line is written to both.
+		if !traceLog.splitDestinations() {
+			traceHandle = combineHandles(traceFile, traceHandle)
+			infoHandle = combineHandles(infoFile, infoHandle)
+			warnHandle = combineHandles(warnFile, warnHandle)
+			errorHandle = combineHandles(errorFile, errorHandle)
+			traceFile, infoFile, warnFile, errorFile = levelHandles(0, nil, nil)
+		}
+	}
+
+	traceLog.Trace = traceLog.interceptHandle(LEVEL_TRACE, traceHandle)
+	traceLog.Info = traceLog.interceptHandle(LEVEL_INFO, infoHandle)
+	traceLog.Warning = traceLog.interceptHandle(LEVEL_WARN, warnHandle)
+	traceLog.Error = traceLog.interceptHandle(LEVEL_ERROR, errorHandle)
+	traceLog.TraceFile = traceLog.interceptHandle(LEVEL_TRACE, traceFile)
+	traceLog.InfoFile = traceLog.interceptHandle(LEVEL_INFO, infoFile)
+	traceLog.WarningFile = traceLog.interceptHandle(LEVEL_WARN, warnFile)
+	traceLog.ErrorFile = traceLog.interceptHandle(LEVEL_ERROR, errorFile)
+	traceLog.ConsoleLevel = consoleLevel
+	traceLog.FileLevel = fileLevel
+	traceLog.Destinations = destinations
+
+	atomic.StoreInt32(&traceLog.LogLevel, consoleLevel|fileLevel)
+}
+
+// output completes the record with the time and caller and writes it to the
+// destination for the record's level. The calldepth is the number of stack
+// frames to skip to find the caller, a value of 1 is the caller of output. The
+// time and caller of a record that already has them are kept. It reports if
+// the record was written.
+func (traceLog *traceLog) output(calldepth int, rec Record) bool {
+	if !traceLog.nilError(&rec) {
+		return false
+	}
+
+	if index := levelIndex(rec.Level); index >= 0 {
+		atomic.AddInt64(&traceLog.Counts[index], 1)
+	}
+
+	if !traceLog.enabled(rec.Level) {
+		return false
+	}
+
+	// Fields added below must not write into a slice owned by the caller.
+	rec.Fields = rec.Fields[:len(rec.Fields):len(rec.Fields)]
+
+	traceLog.Serialize.Lock()
+	maxLineLength, nameLength := traceLog.MaxLineLength, traceLog.nameLength()
+	includeTemplate, includeArgs := traceLog.IncludeTemplate, traceLog.IncludeArgs
+	traceLog.Serialize.Unlock()
+
+	rec.Message = truncateMessage(rec.Message, maxLineLength)
+
+	// A title or function name built from bad input must not bloat every line.
+	rec.Title = truncateMessage(rec.Title, nameLength)
+	rec.Function = truncateMessage(rec.Function, nameLength)
+	rec.Tag = traceLog.localTag(rec.Tag)
+
+	if id, ok := requestID(); ok {
+		rec.Fields = append(rec.Fields, Field{Key: "request_id", Value: id})
+	}
+
+	if v := buildVersion(); v != "" {
+		rec.Fields = append(rec.Fields, Field{Key: "version", Value: v})
+	}
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	info, ok := rec.caller, rec.caller != nil
+	if !ok {
+		info, ok = lookupCaller(calldepth)
+	}
+
+	rec.Caller = "???:0"
+	if ok {
+		rec.Caller = info.caller
+
+		traceLog.Serialize.Lock()
+		limiter, includeFuncName := traceLog.RateLimiter, traceLog.IncludeFuncName
+		traceLog.Serialize.Unlock()
+
+		if limiter != nil {
+			allowed, suppressed := limiter.allow(info.location, rec.Time)
+			if !allowed {
+				atomic.AddInt64(&traceLog.SuppressedLines, 1)
+				return false
+			}
+
+			if suppressed > 0 {
+				rec.Fields = append(rec.Fields, Field{Key: "suppressed", Value: suppressed})
+			}
+		}
+
+		if includeFuncName {
+			rec.Fields = append(rec.Fields, Field{Key: "func", Value: info.function})
+		}
+	}
+
+	if rec.template != "" && includeTemplate {
+		rec.Fields = append(rec.Fields, Field{Key: "template", Value: rec.template})
+		if includeArgs && len(rec.args) > 0 {
+			rec.Fields = append(rec.Fields, Field{Key: "args", Value: rec.args})
+		}
+	}
+
+	if !traceLog.filter(&rec) {
+		return false
+	}
+
+	traceLog.sanitizeKeys(&rec)
+
+	if rec.Level == LEVEL_ERROR {
+		traceLog.Serialize.Lock()
+		summary := traceLog.ErrorSummary
+		traceLog.Serialize.Unlock()
+
+		if summary != nil {
+			summary.add(rec)
+		}
+	}
+
+	traceLog.emit(rec)
+	traceLog.emailOnLevel(rec)
+
+	// Only the application's own error calls end the program, not the
+	// errors this package writes about itself.
+	if rec.errorCall && rec.Level == LEVEL_ERROR {
+		traceLog.failFast()
+	}
+
+	return true
+}
+
+// internalErrorf writes an error raised by the package itself. It's not an error
+// call of the application, so fail fast doesn't apply to it.
+func internalErrorf(err error, functionName string, format string, a ...interface{}) {
+	logger.output(2, Record{Level: LEVEL_ERROR, Title: "main", Function: functionName, Tag: "ERROR", Message: fmt.Sprintf(format, a...), Err: err})
+}
+
+// internalCompletedError writes a Completed tag for a function of the package
+// that failed, like CompletedError without fail fast.
+func internalCompletedError(err error, functionName string) {
+	logger.output(2, Record{Level: LEVEL_ERROR, Title: "main", Function: functionName, Tag: "Completed : ERROR", Err: err})
+}
 
-	if logLevel&LEVEL_TRACE != 0 {
-		traceHandle = os.Stdout
-		infoHandle = os.Stdout
-		warnHandle = os.Stdout
-		errorHandle = os.Stderr
+// enabled reports if a destination or sink accepts the level.
+func (traceLog *traceLog) enabled(level int32) bool {
+	if atomic.LoadInt32(&traceLog.Paused) != 0 {
+		return false
 	}
 
-	if logLevel&LEVEL_INFO != 0 {
-		infoHandle = os.Stdout
-		warnHandle = os.Stdout
-		errorHandle = os.Stderr
+	return levelEnabled(atomic.LoadInt32(&traceLog.LogLevel)|atomic.LoadInt32(&traceLog.SinkLevel), level)
+}
+
+// emit encodes the completed record and writes it to the destination for the
+// record's level and to the sinks.
+func (traceLog *traceLog) emit(rec Record) {
+	if !traceLog.enabled(rec.Level) {
+		return
 	}
 
-	if logLevel&LEVEL_WARN != 0 {
-		warnHandle = os.Stdout
-		errorHandle = os.Stderr
+	traceLog.Serialize.Lock()
+	keys, patterns := traceLog.RedactKeys, traceLog.RedactPatterns
+	encoder, fileEncoder, ending := traceLog.consoleEncoder(), traceLog.fileEncoder(), traceLog.LineEnding
+	handle, fileHandle := traceLog.handle(rec.Level), traceLog.fileHandle(rec.Level)
+	async := traceLog.Async
+	rec.prefixes, rec.levelFlags = traceLog.Prefixes, traceLog.LevelFlags
+	traceLog.Serialize.Unlock()
+
+	// Redact and encode without the lock so one goroutine formatting a large
+	// record doesn't hold up the others. Only the write is serialized.
+	rec = redactRecord(rec, keys, patterns)
+
+	var line, fileLine []byte
+	if handle != nil && handle != ioutil.Discard {
+		line = applyLineEnding(encoder.Encode(rec), ending)
+	}
+	if fileHandle != nil && fileHandle != ioutil.Discard {
+		fileLine = applyLineEnding(fileEncoder.Encode(rec), ending)
 	}
 
-	if logLevel&LEVEL_ERROR != 0 {
-		errorHandle = os.Stderr
+	if async == nil {
+		traceLog.Serialize.Lock()
+		traceLog.write(line, fileLine, rec)
+		traceLog.Serialize.Unlock()
+		return
+	}
+
+	async.enqueue(func() {
+		traceLog.Serialize.Lock()
+		traceLog.write(line, fileLine, rec)
+		traceLog.Serialize.Unlock()
+	})
+}
+
+// write sends the encoded lines to the destinations for the record's level and
+// the record to the sinks. The fileLine is only written when the file has its
+// own encoder. The destinations are looked up at the time of the write so queued
+// lines follow a rotation or level change. The Serialize lock must be held by
+// the caller.
+func (traceLog *traceLog) write(line []byte, fileLine []byte, rec Record) {
+	traceLog.roll(rec.Time)
+
+	if handle := traceLog.handle(rec.Level); handle != nil && handle != ioutil.Discard {
+		if line == nil {
+			line = applyLineEnding(traceLog.consoleEncoder().Encode(rec), traceLog.LineEnding)
+		}
+
+		traceLog.writeHandle(handle, line)
 	}
 
-	if fileHandle != nil {
-		if traceHandle == os.Stdout {
-			traceHandle = io.MultiWriter(fileHandle, traceHandle)
+	if fileHandle := traceLog.fileHandle(rec.Level); fileHandle != nil && fileHandle != ioutil.Discard {
+		if fileLine == nil {
+			fileLine = applyLineEnding(traceLog.fileEncoder().Encode(rec), traceLog.LineEnding)
 		}
 
-		if infoHandle == os.Stdout {
-			infoHandle = io.MultiWriter(fileHandle, infoHandle)
+		traceLog.writeHandle(fileHandle, fileLine)
+	}
+
+	// Don't leave an error sitting in the file buffer.
+	if rec.Level == LEVEL_ERROR {
+		traceLog.flush()
+	}
+
+	for _, entry := range traceLog.Sinks {
+		if levelEnabled(entry.logLevel, rec.Level) {
+			sink := entry.sink
+			err := traceLog.timedWrite(entry.busy, func() error {
+				return sink.WriteRecord(rec)
+			})
+
+			if err != nil && traceLog.Fallback != nil {
+				traceLog.Fallback.Write(traceLog.encode(rec))
+			}
 		}
+	}
+}
+
+// writeHandle writes the line to the handle, sending it to the fallback writer
+// when the write fails. The Serialize lock must be held by the caller.
+func (traceLog *traceLog) writeHandle(handle io.Writer, line []byte) {
+	if _, err := handle.Write(line); err != nil && traceLog.Fallback != nil {
+		traceLog.Fallback.Write(line)
+	}
+}
+
+// SetFallbackWriter registers a writer that receives a line whenever writing it
+// to its destination or a sink fails, for example when the disk is full. Passing
+// nil removes the fallback.
+func SetFallbackWriter(fallback io.Writer) {
+	logger.Serialize.Lock()
+	logger.Fallback = fallback
+	logger.Serialize.Unlock()
+}
+
+// encoder returns the configured encoder or the text encoder if none is set.
+func (traceLog *traceLog) encoder() Encoder {
+	if traceLog.Encoder == nil {
+		return TextEncoder{}
+	}
+
+	return traceLog.Encoder
+}
+
+// consoleEncoder returns the encoder for the console, which is the configured
+// encoder unless SetConsoleEncoder has set one.
+func (traceLog *traceLog) consoleEncoder() Encoder {
+	if traceLog.ConsoleEncoder != nil {
+		return traceLog.ConsoleEncoder
+	}
+
+	return traceLog.encoder()
+}
+
+// fileEncoder returns the encoder for the log file, which is the configured
+// encoder unless SetFileEncoder has set one.
+func (traceLog *traceLog) fileEncoder() Encoder {
+	if traceLog.FileEncoder != nil {
+		return traceLog.FileEncoder
+	}
+
+	return traceLog.encoder()
+}
+
+// splitDestinations reports if the console and the file have their own
+// encoders, so each gets its own lines.
+func (traceLog *traceLog) splitDestinations() bool {
+	return traceLog.ConsoleEncoder != nil || traceLog.FileEncoder != nil
+}
+
+// encode encodes the record and applies the configured line ending.
+func (traceLog *traceLog) encode(rec Record) []byte {
+	return applyLineEnding(traceLog.encoder().Encode(rec), traceLog.LineEnding)
+}
+
+// writeFileHeader writes the encoder's header, if it has one, to the log file
+// and the level files that are not in previousLevelFiles. The files must have
+// just been created.
+func (traceLog *traceLog) writeFileHeader(previousLevelFiles map[int32]*os.File) {
+	headerEncoder, ok := traceLog.fileEncoder().(HeaderEncoder)
+	if !ok || traceLog.FileHandle == nil {
+		return
+	}
 
-		if warnHandle == os.Stdout {
-			warnHandle = io.MultiWriter(fileHandle, warnHandle)
+	header := applyLineEnding(headerEncoder.Header(), traceLog.LineEnding)
+	traceLog.FileHandle.Write(header)
+
+	written := make(map[*os.File]bool)
+	for _, logf := range previousLevelFiles {
+		written[logf] = true
+	}
+
+	for _, logf := range traceLog.LevelFiles {
+		if !written[logf] {
+			logf.Write(header)
+			written[logf] = true
 		}
+	}
+}
 
-		if errorHandle == os.Stderr {
-			errorHandle = io.MultiWriter(fileHandle, errorHandle)
+// applyLineEnding replaces the line breaks in an encoded line with the line
+// ending. Every line break the encoder wrote, including ones inside a multiline
+// message, uses the same terminator so the file never mixes line endings.
+func applyLineEnding(line []byte, lineEnding string) []byte {
+	if lineEnding == "" || lineEnding == "\n" {
+		return line
+	}
+
+	ending := []byte(lineEnding)
+	converted := make([]byte, 0, len(line)+len(ending))
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\r' && i+1 < len(line) && line[i+1] == '\n':
+			converted = append(converted, ending...)
+			i++
+		case line[i] == '\n':
+			converted = append(converted, ending...)
+		default:
+			converted = append(converted, line[i])
 		}
 	}
 
-	logger = traceLog{
-		Trace:   log.New(traceHandle, "TRACE: ", log.Ldate|log.Ltime|log.Lshortfile),
-		Info:    log.New(infoHandle, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile),
-		Warning: log.New(warnHandle, "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile),
-		Error:   log.New(errorHandle, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
+	return converted
+}
+
+// SetIncludeFuncName adds a func field with the name of the calling function,
+// such as main.processOrder, to every line. This is the function the log call
+// was made from, not the functionName argument.
+func SetIncludeFuncName(include bool) {
+	logger.Serialize.Lock()
+	logger.IncludeFuncName = include
+	logger.Serialize.Unlock()
+}
+
+// SetIncludeTemplate adds a template field with the format string of the log
+// call, such as "Order[%d] Shipped", so lines can be grouped by template no
+// matter the arguments. When includeArgs is set the arguments are added as an
+// args field too, note the redact patterns only apply to the message and not
+// to the arguments. Calls without a format string, such as Infow, are unchanged.
+func SetIncludeTemplate(include bool, includeArgs bool) {
+	logger.Serialize.Lock()
+	logger.IncludeTemplate = include
+	logger.IncludeArgs = includeArgs
+	logger.Serialize.Unlock()
+}
+
+// funcName returns the package qualified name of the function, stripping the
+// import path.
+func funcName(name string) string {
+	if name == "" {
+		return "???"
+	}
+
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
+
+// SetMaxLineLength limits the number of bytes of the message written on each
+// line. Longer messages are truncated. A value of 0 removes the limit.
+func SetMaxLineLength(n int) {
+	logger.Serialize.Lock()
+	logger.MaxLineLength = n
+	logger.Serialize.Unlock()
+}
+
+// maxLineLength returns the limit set by SetMaxLineLength, 0 for none.
+func (traceLog *traceLog) maxLineLength() int {
+	traceLog.Serialize.Lock()
+	defer traceLog.Serialize.Unlock()
+
+	return traceLog.MaxLineLength
+}
+
+// defaultMaxNameLength is the longest title or function name written when
+// SetMaxNameLength hasn't been called.
+const defaultMaxNameLength = 256
+
+// SetMaxNameLength limits the number of bytes of the title and the function
+// name written on each line, longer ones are truncated. The default is 256, a
+// negative value removes the limit. The limit never exceeds MaxLineLength.
+func SetMaxNameLength(n int) {
+	if n == 0 {
+		n = defaultMaxNameLength
+	}
+
+	logger.Serialize.Lock()
+	logger.MaxNameLength = n
+	logger.Serialize.Unlock()
+}
+
+// nameLength returns the limit for the title and the function name, 0 for none.
+// The Serialize lock must be held by the caller.
+func (traceLog *traceLog) nameLength() int {
+	max := traceLog.MaxNameLength
+	switch {
+	case max == 0:
+		max = defaultMaxNameLength
+	case max < 0:
+		max = 0
+	}
+
+	if traceLog.MaxLineLength > 0 && (max == 0 || traceLog.MaxLineLength < max) {
+		max = traceLog.MaxLineLength
+	}
+
+	return max
+}
+
+// truncateMessage shortens the message to max bytes without splitting a character.
+func truncateMessage(message string, max int) string {
+	if max <= 0 || len(message) <= max {
+		return message
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+
+	return message[:cut] + "... (truncated)"
+}
+
+// handle returns the destination handle for the logging level.
+func (traceLog *traceLog) handle(level int32) io.Writer {
+	switch level {
+	case LEVEL_TRACE:
+		return traceLog.Trace
+	case LEVEL_INFO:
+		return traceLog.Info
+	case LEVEL_WARN:
+		return traceLog.Warning
+	case LEVEL_ERROR:
+		return traceLog.Error
+	}
+
+	return nil
+}
+
+// fileHandle returns the file destination for the level when the file has its
+// own encoder. Otherwise the file is part of the handle and nil is returned.
+func (traceLog *traceLog) fileHandle(level int32) io.Writer {
+	switch level {
+	case LEVEL_TRACE:
+		return traceLog.TraceFile
+	case LEVEL_INFO:
+		return traceLog.InfoFile
+	case LEVEL_WARN:
+		return traceLog.WarningFile
+	case LEVEL_ERROR:
+		return traceLog.ErrorFile
+	}
+
+	return nil
+}
+
+// SetAlsoStdout writes every line written to the file to stdout and stderr as
+// well, whatever the console level is. This keeps kubectl logs working when the
+// console level is 0 and the lines only go to the files.
+func SetAlsoStdout(alsoStdout bool) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	logger.AlsoStdout = alsoStdout
+	logger.setHandles(logger.ConsoleLevel, logger.FileLevel)
+}
+
+// levelHandles returns the handle for each destination enabled by the logging level.
+// Trace, Info and Warning write to stdHandle and Error writes to errHandle.
+func levelHandles(logLevel int32, stdHandle io.Writer, errHandle io.Writer) (traceHandle, infoHandle, warnHandle, errorHandle io.Writer) {
+	traceHandle = ioutil.Discard
+	infoHandle = ioutil.Discard
+	warnHandle = ioutil.Discard
+	errorHandle = ioutil.Discard
+
+	if levelEnabled(logLevel, LEVEL_TRACE) {
+		traceHandle = stdHandle
+	}
+
+	if levelEnabled(logLevel, LEVEL_INFO) {
+		infoHandle = stdHandle
+	}
+
+	if levelEnabled(logLevel, LEVEL_WARN) {
+		warnHandle = stdHandle
+	}
+
+	if levelEnabled(logLevel, LEVEL_ERROR) {
+		errorHandle = errHandle
+	}
+
+	return traceHandle, infoHandle, warnHandle, errorHandle
+}
+
+// interceptHandle wraps an enabled handle so the line interceptor sees each line written.
+func (traceLog *traceLog) interceptHandle(level int32, handle io.Writer) io.Writer {
+	if handle == ioutil.Discard || traceLog.LineInterceptor == nil {
+		return handle
+	}
+
+	return &interceptWriter{level: level, handle: handle, interceptor: traceLog.LineInterceptor}
+}
+
+// combineHandles joins the file and console handles for a destination.
+func combineHandles(fileHandle io.Writer, consoleHandle io.Writer) io.Writer {
+	if fileHandle == ioutil.Discard {
+		return consoleHandle
+	}
+
+	if consoleHandle == ioutil.Discard {
+		return fileHandle
 	}
 
-	atomic.StoreInt32(&logger.LogLevel, logLevel)
+	return io.MultiWriter(fileHandle, consoleHandle)
+}
+
+// SetLineInterceptor registers a function that receives every formatted line just
+// before it is written to its destination. This is intended for tests and in-process
+// inspection. Passing nil removes the interceptor.
+func SetLineInterceptor(interceptor func(level int32, line string)) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	logger.LineInterceptor = interceptor
+	logger.setHandles(logger.ConsoleLevel, logger.FileLevel)
+}
+
+// interceptWriter passes each line to the line interceptor before writing it.
+type interceptWriter struct {
+	level       int32
+	handle      io.Writer
+	interceptor func(level int32, line string)
+}
+
+// Write implements the io.Writer interface.
+func (interceptWriter *interceptWriter) Write(p []byte) (int, error) {
+	interceptWriter.interceptor(interceptWriter.level, string(p))
+	return interceptWriter.handle.Write(p)
 }
 
 // LogDirectoryCleanup performs all the directory cleanup and maintenance.
+// A daysToKeep of 0 or less disables the cleanup and keeps every directory.
 func (traceLog *traceLog) LogDirectoryCleanup(baseFilePath string, daysToKeep int) {
 	defer traceLog.CatchPanic(nil, "LogDirectoryCleanup")
 
 	Startedf("main", "LogDirectoryCleanup", "BaseFilePath[%s] DaysToKeep[%d]", baseFilePath, daysToKeep)
 
+	if daysToKeep <= 0 {
+		Completedf("main", "LogDirectoryCleanup", "Cleanup Disabled")
+		return
+	}
+
 	// Get a list of existing directories.
 	fileInfos, err := ioutil.ReadDir(baseFilePath)
 	if err != nil {
-		CompletedError(err, "main", "LogDirectoryCleanup")
+		internalCompletedError(err, "LogDirectoryCleanup")
 		return
 	}
 
 	// Create the date to compare for directories to remove.
-	currentDate := time.Now().UTC()
-	compareDate := time.Date(currentDate.Year(), currentDate.Month(), currentDate.Day()-daysToKeep, 0, 0, 0, 0, time.UTC)
+	currentDate := time.Now().In(fileLocation)
+	compareDate := time.Date(currentDate.Year(), currentDate.Month(), currentDate.Day()-daysToKeep, 0, 0, 0, 0, fileLocation)
 
 	Trace("main", "LogDirectoryCleanup", "CompareDate[%v]", compareDate)
 
@@ -302,19 +1475,19 @@ func (traceLog *traceLog) LogDirectoryCleanup(baseFilePath string, daysToKeep in
 
 		year, err := strconv.Atoi(parts[0])
 		if err != nil {
-			Errorf(err, "main", "LogDirectoryCleanup", "Attempting To Convert Directory [%s]", fileInfo.Name())
+			internalErrorf(err, "LogDirectoryCleanup", "Attempting To Convert Directory [%s]", fileInfo.Name())
 			continue
 		}
 
 		month, err := strconv.Atoi(parts[1])
 		if err != nil {
-			Errorf(err, "main", "LogDirectoryCleanup", "Attempting To Convert Directory [%s]", fileInfo.Name())
+			internalErrorf(err, "LogDirectoryCleanup", "Attempting To Convert Directory [%s]", fileInfo.Name())
 			continue
 		}
 
 		day, err := strconv.Atoi(parts[2])
 		if err != nil {
-			Errorf(err, "main", "LogDirectoryCleanup", "Attempting To Convert Directory [%s]", fileInfo.Name())
+			internalErrorf(err, "LogDirectoryCleanup", "Attempting To Convert Directory [%s]", fileInfo.Name())
 			continue
 		}
 
@@ -322,7 +1495,7 @@ func (traceLog *traceLog) LogDirectoryCleanup(baseFilePath string, daysToKeep in
 		fullFileName := fmt.Sprintf("%s/%s", baseFilePath, fileInfo.Name())
 
 		// Create a time type from the directory name.
-		directoryDate := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		directoryDate := time.Date(year, time.Month(month), day, 0, 0, 0, 0, fileLocation)
 
 		// Compare the dates and convert to days.
 		daysOld := int(compareDate.Sub(directoryDate).Hours() / 24)
@@ -350,19 +1523,51 @@ func (traceLog *traceLog) LogDirectoryCleanup(baseFilePath string, daysToKeep in
 }
 
 // CatchPanic is used to catch any Panic and log exceptions to Stdout. It will also write the stack logger.
+// The err is set to a *PanicError, which unwraps to the panic value when it's an
+// error so errors.Is and errors.As still find it.
 func (traceLog *traceLog) CatchPanic(err *error, functionName string) {
 	if r := recover(); r != nil {
-		// Capture the stack trace
-		buf := make([]byte, 10000)
-		runtime.Stack(buf, false)
-
-		SendEmailException(systemAlertSubject, "%s : PANIC Defered [%s] : Stack Trace : %s", functionName, r, string(buf))
+		panicErr := traceLog.logPanic(r, functionName, "")
 		if err != nil {
-			*err = fmt.Errorf("%v", r)
+			*err = panicErr
 		}
 	}
 }
 
+// logPanic reports the recovered panic and writes it as a single PANIC record,
+// with the detail, if any, as the message. It must be called by the deferred
+// function that recovered the panic.
+func (traceLog *traceLog) logPanic(r interface{}, functionName string, detail string) *PanicError {
+	stack := traceLog.reportPanic(r, functionName, detail)
+	panicErr := &PanicError{Function: functionName, Value: r, Stack: stack}
+
+	// Skip the deferred function and the runtime's panic frame so the caller is
+	// where the panic was raised.
+	traceLog.output(4, Record{Level: LEVEL_ERROR, Title: "main", Function: functionName, Tag: "PANIC", Message: detail, Err: panicErr, Fields: []Field{
+		{Key: "panic_type", Value: fmt.Sprintf("%T", r)},
+		{Key: "panic_value", Value: r},
+		{Key: "stack", Value: stack},
+	}})
+
+	return panicErr
+}
+
+// reportPanic captures the stack trace and emails the recovered panic value.
+// The detail, if any, is added to the email ahead of the stack. The stack is
+// returned so the caller can log it.
+func (traceLog *traceLog) reportPanic(r interface{}, functionName string, detail string) string {
+	// Capture the stack trace
+	stack := traceLog.panicStack()
+
+	if detail != "" {
+		SendEmailException(systemAlertSubject, "%s : PANIC Defered [%s] : %s : Stack Trace : %s", functionName, r, detail, stack)
+		return stack
+	}
+
+	SendEmailException(systemAlertSubject, "%s : PANIC Defered [%s] : Stack Trace : %s", functionName, r, stack)
+	return stack
+}
+
 // EmailScript returns a template for the email message to be sent.
 func (traceLog *traceLog) EmailScript() (script string) {
 	return `From: {{.From}}
@@ -371,5 +1576,8 @@ Subject: {{.Subject}}
 MIME-version: 1.0
 Content-Type: text/html; charset="UTF-8"
 
-<html><body>{{.Message}}</body></html>`
+<html><body>
+<p>{{.Severity}} on {{.Host}} at {{.Timestamp.Format "2006/01/02 15:04:05 MST"}}</p>
+{{.Message}}
+</body></html>`
 }
@@ -0,0 +1,61 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// makeDateDirs creates a date directory for each of the days before today and
+// returns their names.
+func makeDateDirs(t *testing.T, baseFilePath string, daysAgo ...int) []string {
+	now := time.Now().In(fileLocation)
+
+	names := make([]string, len(daysAgo))
+	for i, days := range daysAgo {
+		names[i] = now.AddDate(0, 0, -days).Format("2006-01-02")
+		if err := os.MkdirAll(filepath.Join(baseFilePath, names[i]), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return names
+}
+
+// TestLogDirectoryCleanupDisabled checks that a daysToKeep of 0 or less keeps
+// every directory, today's included.
+func TestLogDirectoryCleanupDisabled(t *testing.T) {
+	for _, daysToKeep := range []int{0, -1} {
+		baseFilePath := t.TempDir()
+		names := makeDateDirs(t, baseFilePath, 0, 1, 30, 400)
+
+		logger.LogDirectoryCleanup(baseFilePath, daysToKeep)
+
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(baseFilePath, name)); err != nil {
+				t.Errorf("DaysToKeep[%d] : directory %s was removed", daysToKeep, name)
+			}
+		}
+	}
+}
+
+// TestLogDirectoryCleanup checks that only the directories older than daysToKeep
+// are removed.
+func TestLogDirectoryCleanup(t *testing.T) {
+	baseFilePath := t.TempDir()
+	names := makeDateDirs(t, baseFilePath, 0, 1, 30, 400)
+
+	logger.LogDirectoryCleanup(baseFilePath, 7)
+
+	for i, name := range names {
+		_, err := os.Stat(filepath.Join(baseFilePath, name))
+		if kept := err == nil; kept != (i < 2) {
+			t.Errorf("directory %s kept = %v, want %v", name, kept, i < 2)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package log
 
 import (
+	"encoding/hex"
 	"fmt"
 )
 
@@ -11,79 +12,162 @@ import (
 
 // Started uses the Serialize destination and adds a Started tag to the log line
 func Started(title string, functionName string) {
-	logger.Trace.Output(2, fmt.Sprintf("%s : %s : Started\n", title, functionName))
+	logger.output(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Started"})
 }
 
 // Startedf uses the Serialize destination and writes a Started tag to the log line
 func Startedf(title string, functionName string, format string, a ...interface{}) {
-	logger.Trace.Output(2, fmt.Sprintf("%s : %s : Started : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Started", Message: fmt.Sprintf(format, a...), template: format, args: a})
 }
 
 // Completed uses the Serialize destination and writes a Completed tag to the log line
 func Completed(title string, functionName string) {
-	logger.Trace.Output(2, fmt.Sprintf("%s : %s : Completed\n", title, functionName))
+	logger.output(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Completed"})
 }
 
 // COMPLETEDf uses the Serialize destination and writes a Completed tag to the log line
 func Completedf(title string, functionName string, format string, a ...interface{}) {
-	logger.Trace.Output(2, fmt.Sprintf("%s : %s : Completed : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Completed", Message: fmt.Sprintf(format, a...), template: format, args: a})
 }
 
 // CompletedError uses the Error destination and writes a Completed tag to the log line
 func CompletedError(err error, title string, functionName string) {
-	logger.Error.Output(2, fmt.Sprintf("%s : %s : Completed : ERROR : %s\n", title, functionName, err))
+	logger.output(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ERROR", Err: err, errorCall: true})
 }
 
 // CompletedErrorf uses the Error destination and writes a Completed tag to the log line
 func CompletedErrorf(err error, title string, functionName string, format string, a ...interface{}) {
-	logger.Error.Output(2, fmt.Sprintf("%s : %s : Completed : ERROR : %s : %s\n", title, functionName, fmt.Sprintf(format, a...), err))
+	logger.output(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ERROR", Message: fmt.Sprintf(format, a...), template: format, args: a, Err: err, errorCall: true})
+}
+
+// CompletedResult writes a Completed tag with an outcome field so dashboards can
+// count the results of a task. Extra fields such as the duration or the number of
+// items processed are added after the outcome. A failure uses the Error destination
+// and any other outcome the Trace destination.
+//
+//	log.CompletedResult("main", "Import", log.OutcomeSuccess, log.Field{Key: "count", Value: 120})
+func CompletedResult(title string, functionName string, outcome Outcome, fields ...Field) {
+	level := LEVEL_TRACE
+	if outcome == OutcomeFailure {
+		level = LEVEL_ERROR
+	}
+
+	logger.output(2, Record{Level: level, Title: title, Function: functionName, Tag: "Completed", Fields: append([]Field{{Key: "outcome", Value: outcome.String()}}, fields...)})
 }
 
 //** TRACE
 
 // Trace writes to the Trace destination
 func Trace(title string, functionName string, format string, a ...interface{}) {
-	logger.Trace.Output(2, fmt.Sprintf("%s : %s : Info : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
+}
+
+// Tracew writes to the Trace destination with fields attached to the line
+func Tracew(title string, functionName string, message string, fields ...Field) {
+	logger.output(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: message, Fields: fields})
+}
+
+// compactHexSize is the largest payload TraceHex writes on a single line.
+const compactHexSize = 32
+
+// TraceHex writes the bytes to the Trace destination as hex. Small payloads are
+// written on a single line, larger ones as a full hex dump.
+func TraceHex(title string, functionName string, label string, data []byte) {
+	logger.output(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: formatHex(label, data)})
+}
+
+// formatHex renders the bytes for TraceHex, limiting the bytes dumped to the
+// maximum line length so a huge buffer isn't formatted only to be truncated.
+func formatHex(label string, data []byte) string {
+	size := len(data)
+	if max := logger.maxLineLength(); max > 0 && len(data) > max {
+		data = data[:max]
+	}
+
+	if size <= compactHexSize {
+		return fmt.Sprintf("%s[%d] %s", label, size, hex.EncodeToString(data))
+	}
+
+	return fmt.Sprintf("%s[%d]\n%s", label, size, hex.Dump(data))
 }
 
 //** INFO
 
 // Info writes to the Info destination
 func Info(title string, functionName string, format string, a ...interface{}) {
-	logger.Info.Output(2, fmt.Sprintf("%s : %s : Info : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(2, Record{Level: LEVEL_INFO, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
+}
+
+// Infow writes to the Info destination with fields attached to the line
+func Infow(title string, functionName string, message string, fields ...Field) {
+	logger.output(2, Record{Level: LEVEL_INFO, Title: title, Function: functionName, Tag: "Info", Message: message, Fields: fields})
 }
 
 //** WARNING
 
 // Warning writes to the Warning destination
 func Warning(title string, functionName string, format string, a ...interface{}) {
-	logger.Warning.Output(2, fmt.Sprintf("%s : %s : Info : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(2, Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
+}
+
+// Warningw writes to the Warning destination with fields attached to the line
+func Warningw(title string, functionName string, message string, fields ...Field) {
+	logger.output(2, Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "Info", Message: message, Fields: fields})
 }
 
 //** ERROR
 
 // Error writes to the Error destination and accepts an err
 func Error(err error, title string, functionName string) {
-	logger.Error.Output(2, fmt.Sprintf("%s : %s : ERROR : %s\n", title, functionName, err))
+	logger.output(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Err: err, errorCall: true})
 }
 
 // Errorf writes to the Error destination and accepts an err
 func Errorf(err error, title string, functionName string, format string, a ...interface{}) {
-	logger.Error.Output(2, fmt.Sprintf("%s : %s : ERROR : %s : %s\n", title, functionName, fmt.Sprintf(format, a...), err))
+	logger.output(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Message: fmt.Sprintf(format, a...), template: format, args: a, Err: err, errorCall: true})
+}
+
+// Errorw writes to the Error destination, accepts an err and attaches fields to the line
+func Errorw(err error, title string, functionName string, message string, fields ...Field) {
+	logger.output(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Message: message, Err: err, Fields: fields, errorCall: true})
+}
+
+// ErrorStack writes to the Error destination, accepts an err and adds a stack field
+// with the frames where the error is logged. The frames use the panic stack depth
+// and frame filter
+func ErrorStack(err error, title string, functionName string) {
+	logger.output(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Err: err, Fields: []Field{{Key: "stack", Value: logger.callStack(1)}}, errorCall: true})
 }
 
 //** ALERT
 
 // Alert write to the Error destination and sends email alert
 func Alert(subject string, title string, functionName string, format string, a ...interface{}) {
-	message := fmt.Sprintf("%s : %s : ALERT : %s\n", title, functionName, fmt.Sprintf(format, a...))
-	logger.Error.Output(2, message)
-	SendEmailException(subject, message)
+	message := fmt.Sprintf(format, a...)
+	written := logger.output(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ALERT", Message: message, template: format, args: a})
+	sendAlert("ERROR", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.localTag("ALERT"), message))
+
+	// Fail fast only once the alert has been sent.
+	if written {
+		logger.failFast()
+	}
 }
 
 // CompletedAlert write to the Error destination, writes a Completed tag to the log line and sends email alert
 func CompletedAlert(subject string, title string, functionName string, format string, a ...interface{}) {
-	message := fmt.Sprintf("%s : %s : Completed : ALERT : %s\n", title, functionName, fmt.Sprintf(format, a...))
-	logger.Error.Output(2, message)
-	SendEmailException(subject, message)
+	message := fmt.Sprintf(format, a...)
+	written := logger.output(2, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ALERT", Message: message, template: format, args: a})
+	sendAlert("ERROR", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.localTag("Completed : ALERT"), message))
+
+	// Fail fast only once the alert has been sent.
+	if written {
+		logger.failFast()
+	}
+}
+
+// WarnAlert write to the Warning destination and sends email alert
+func WarnAlert(subject string, title string, functionName string, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	logger.output(2, Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "ALERT", Message: message, template: format, args: a})
+	sendAlert("WARNING", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.localTag("ALERT"), message))
 }
@@ -0,0 +1,120 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// slowStringer is an argument whose String method is expensive, like a large
+// struct or a formatted report.
+type slowStringer struct{}
+
+// String implements the fmt.Stringer interface.
+func (slowStringer) String() string {
+	var buf bytes.Buffer
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&buf, "%d,", i)
+	}
+
+	return buf.String()
+}
+
+// lineWriter keeps the lines written to it. Writes are serialized by the logger.
+type lineWriter struct {
+	lines []string
+}
+
+// Write implements the io.Writer interface.
+func (lineWriter *lineWriter) Write(p []byte) (int, error) {
+	lineWriter.lines = append(lineWriter.lines, string(p))
+	return len(p), nil
+}
+
+// countWriter counts the lines written to it. Writes are serialized by the logger.
+type countWriter struct {
+	lines int
+}
+
+// Write implements the io.Writer interface.
+func (countWriter *countWriter) Write(p []byte) (int, error) {
+	countWriter.lines++
+	return len(p), nil
+}
+
+// BenchmarkInfoSlowStringer logs from parallel goroutines with expensive
+// arguments. The message is formatted before the lock is taken, so the
+// goroutines only wait on each other for the write. FormatLocked formats while
+// holding the lock, the way the log calls used to, for comparison. The
+// difference grows with the number of CPUs.
+func BenchmarkInfoSlowStringer(b *testing.B) {
+	turnOnLogging(0, LEVEL_INFO, &countWriter{})
+	defer turnOnLogging(0, 0, nil)
+
+	b.Run("FormatUnlocked", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				Info("main", "Benchmark", "First[%v] Second[%v]", slowStringer{}, slowStringer{})
+			}
+		})
+	})
+
+	b.Run("FormatLocked", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Serialize.Lock()
+				message := fmt.Sprintf("First[%v] Second[%v]", slowStringer{}, slowStringer{})
+				logger.Serialize.Unlock()
+
+				Info("main", "Benchmark", "%s", message)
+			}
+		})
+	})
+}
+
+// TestInfoOrder checks that the lines of each goroutine are written in the order
+// they were logged while the goroutines format their messages in parallel.
+func TestInfoOrder(t *testing.T) {
+	writer := &lineWriter{}
+	turnOnLogging(0, LEVEL_INFO, writer)
+	defer turnOnLogging(0, 0, nil)
+
+	const goroutines, lines = 8, 200
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < lines; n++ {
+				Info("main", "TestInfoOrder", "Goroutine[%d] Line[%d] Arg[%v]", g, n, slowStringer{})
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if len(writer.lines) != goroutines*lines {
+		t.Fatalf("wrote %d lines, want %d", len(writer.lines), goroutines*lines)
+	}
+
+	next := make([]int, goroutines)
+	for _, line := range writer.lines {
+		var g, n int
+		i := strings.Index(line, "Goroutine[")
+		if i < 0 {
+			t.Fatalf("unexpected line %q", line)
+		}
+		fmt.Sscanf(line[i:], "Goroutine[%d] Line[%d]", &g, &n)
+
+		if n != next[g] {
+			t.Fatalf("goroutine %d wrote line %d, want %d", g, n, next[g])
+		}
+		next[g]++
+	}
+}
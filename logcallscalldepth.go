@@ -12,79 +12,96 @@ import (
 
 // Startedcd uses the Trace destination and adds a Started tag to the log line
 func Startedcd(callDepth int, title string, functionName string) {
-	logger.Trace.Output(callDepth, fmt.Sprintf("%s : %s : Started\n", title, functionName))
+	logger.output(callDepth, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Started"})
 }
 
 // Startedfcd uses the Trace destination and writes a Started tag to the log line
 func Startedfcd(callDepth int, title string, functionName string, format string, a ...interface{}) {
-	logger.Trace.Output(callDepth, fmt.Sprintf("%s : %s : Started : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(callDepth, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Started", Message: fmt.Sprintf(format, a...), template: format, args: a})
 }
 
 // Completedcd uses the Trace destination and writes a Completed tag to the log line
 func Completedcd(callDepth int, title string, functionName string) {
-	logger.Trace.Output(callDepth, fmt.Sprintf("%s : %s : Completed\n", title, functionName))
+	logger.output(callDepth, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Completed"})
 }
 
 // Completedfcd uses the Trace destination and writes a Completed tag to the log line
 func Completedfcd(callDepth int, title string, functionName string, format string, a ...interface{}) {
-	logger.Trace.Output(callDepth, fmt.Sprintf("%s : %s : Completed : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(callDepth, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Completed", Message: fmt.Sprintf(format, a...), template: format, args: a})
 }
 
 // CompletedErrorcd uses the Error destination and writes a Completed tag to the log line
 func CompletedErrorcd(callDepth int, err error, title string, functionName string) {
-	logger.Error.Output(callDepth, fmt.Sprintf("%s : %s : Completed : ERROR : %s\n", title, functionName, err))
+	logger.output(callDepth, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ERROR", Err: err, errorCall: true})
 }
 
 // CompletedErrorfcd uses the Error destination and writes a Completed tag to the log line
 func CompletedErrorfcd(callDepth int, err error, title string, functionName string, format string, a ...interface{}) {
-	logger.Error.Output(callDepth, fmt.Sprintf("%s : %s : Completed : ERROR : %s : %s\n", title, functionName, fmt.Sprintf(format, a...), err))
+	logger.output(callDepth, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ERROR", Message: fmt.Sprintf(format, a...), template: format, args: a, Err: err, errorCall: true})
 }
 
 //** TRACE
 
 // Tracecd writes to the Trace destination
 func Tracecd(callDepth int, title string, functionName string, format string, a ...interface{}) {
-	logger.Trace.Output(callDepth, fmt.Sprintf("%s : %s : Info : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(callDepth, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
 }
 
 //** INFO
 
 // Infocd writes to the Info destination
 func Infocd(callDepth int, title string, functionName string, format string, a ...interface{}) {
-	logger.Info.Output(callDepth, fmt.Sprintf("%s : %s : Info : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(callDepth, Record{Level: LEVEL_INFO, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
 }
 
 //** WARNING
 
 // Warningcd writes to the Warning destination
 func Warningcd(callDepth int, title string, functionName string, format string, a ...interface{}) {
-	logger.Warning.Output(callDepth, fmt.Sprintf("%s : %s : Info : %s\n", title, functionName, fmt.Sprintf(format, a...)))
+	logger.output(callDepth, Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
 }
 
 //** ERROR
 
 // Errorcd writes to the Error destination and accepts an err
 func Errorcd(callDepth int, err error, title string, functionName string) {
-	logger.Error.Output(callDepth, fmt.Sprintf("%s : %s : ERROR : %s\n", title, functionName, err))
+	logger.output(callDepth, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Err: err, errorCall: true})
 }
 
 // Errorfcd writes to the Error destination and accepts an err
 func Errorfcd(callDepth int, err error, title string, functionName string, format string, a ...interface{}) {
-	logger.Error.Output(callDepth, fmt.Sprintf("%s : %s : ERROR : %s : %s\n", title, functionName, fmt.Sprintf(format, a...), err))
+	logger.output(callDepth, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Message: fmt.Sprintf(format, a...), template: format, args: a, Err: err, errorCall: true})
 }
 
 //** ALERT
 
 // Alertcd write to the Error destination and sends email alert
 func Alertcd(callDepth int, subject string, title string, functionName string, format string, a ...interface{}) {
-	message := fmt.Sprintf("%s : %s : ALERT : %s\n", title, functionName, fmt.Sprintf(format, a...))
-	logger.Error.Output(callDepth, message)
-	SendEmailException(subject, message)
+	message := fmt.Sprintf(format, a...)
+	written := logger.output(callDepth, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ALERT", Message: message, template: format, args: a})
+	sendAlert("ERROR", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.localTag("ALERT"), message))
+
+	// Fail fast only once the alert has been sent.
+	if written {
+		logger.failFast()
+	}
 }
 
 // CompletedAlertcd write to the Error destination, writes a Completed tag to the log line and sends email alert
 func CompletedAlertcd(callDepth int, subject string, title string, functionName string, format string, a ...interface{}) {
-	message := fmt.Sprintf("%s : %s : Completed : ALERT : %s\n", title, functionName, fmt.Sprintf(format, a...))
-	logger.Error.Output(callDepth, message)
-	SendEmailException(subject, message)
+	message := fmt.Sprintf(format, a...)
+	written := logger.output(callDepth, Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ALERT", Message: message, template: format, args: a})
+	sendAlert("ERROR", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.localTag("Completed : ALERT"), message))
+
+	// Fail fast only once the alert has been sent.
+	if written {
+		logger.failFast()
+	}
+}
+
+// WarnAlertcd write to the Warning destination and sends email alert
+func WarnAlertcd(callDepth int, subject string, title string, functionName string, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	logger.output(callDepth, Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "ALERT", Message: message, template: format, args: a})
+	sendAlert("WARNING", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.localTag("ALERT"), message))
 }
@@ -0,0 +1,191 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+)
+
+// Logger writes through a logging system. The methods match the log calls of
+// the package, from Started to WarnAlert, so code can move from log.Info to a
+// Logger one call at a time and later have a Logger injected.
+type Logger struct {
+	traceLog *traceLog
+	fields   []Field
+}
+
+// defaultLogger is the Logger returned by Default.
+var defaultLogger = &Logger{traceLog: &logger}
+
+// Default returns the Logger used by the package level functions. Start, Stop
+// and the other package level settings apply to it.
+//
+//	logger := log.Default()
+//	logger.Info("main", "main", "Hello Info")
+func Default() *Logger {
+	return defaultLogger
+}
+
+// With returns a child Logger that adds the fields to every line ahead of the
+// fields of the call. The child shares the destinations of its parent, so it's
+// cheap to create and needs no Stop of its own.
+//
+//	orderLog := log.Default().With(log.Field{Key: "order", Value: id})
+func (logger *Logger) With(fields ...Field) *Logger {
+	combined := make([]Field, 0, len(logger.fields)+len(fields))
+	combined = append(combined, logger.fields...)
+	combined = append(combined, fields...)
+
+	return &Logger{traceLog: logger.traceLog, fields: combined}
+}
+
+// output adds the fields of the Logger to the record and writes it. It reports
+// if the record was written.
+func (logger *Logger) output(rec Record) bool {
+	if len(logger.fields) > 0 {
+		rec.Fields = append(logger.fields[:len(logger.fields):len(logger.fields)], rec.Fields...)
+	}
+
+	return logger.traceLog.output(3, rec)
+}
+
+//** STARTED AND COMPLETED
+
+// Started writes a Started tag to the Trace destination
+func (logger *Logger) Started(title string, functionName string) {
+	logger.output(Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Started"})
+}
+
+// Startedf writes a Started tag to the Trace destination
+func (logger *Logger) Startedf(title string, functionName string, format string, a ...interface{}) {
+	logger.output(Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Started", Message: fmt.Sprintf(format, a...), template: format, args: a})
+}
+
+// Completed writes a Completed tag to the Trace destination
+func (logger *Logger) Completed(title string, functionName string) {
+	logger.output(Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Completed"})
+}
+
+// Completedf writes a Completed tag to the Trace destination
+func (logger *Logger) Completedf(title string, functionName string, format string, a ...interface{}) {
+	logger.output(Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Completed", Message: fmt.Sprintf(format, a...), template: format, args: a})
+}
+
+// CompletedError writes a Completed tag to the Error destination
+func (logger *Logger) CompletedError(err error, title string, functionName string) {
+	logger.output(Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ERROR", Err: err, errorCall: true})
+}
+
+// CompletedErrorf writes a Completed tag to the Error destination
+func (logger *Logger) CompletedErrorf(err error, title string, functionName string, format string, a ...interface{}) {
+	logger.output(Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ERROR", Message: fmt.Sprintf(format, a...), template: format, args: a, Err: err, errorCall: true})
+}
+
+// CompletedResult writes a Completed tag with an outcome field to the Error
+// destination for a failure and the Trace destination otherwise
+func (logger *Logger) CompletedResult(title string, functionName string, outcome Outcome, fields ...Field) {
+	level := LEVEL_TRACE
+	if outcome == OutcomeFailure {
+		level = LEVEL_ERROR
+	}
+
+	logger.output(Record{Level: level, Title: title, Function: functionName, Tag: "Completed", Fields: append([]Field{{Key: "outcome", Value: outcome.String()}}, fields...)})
+}
+
+//** TRACE
+
+// Trace writes to the Trace destination
+func (logger *Logger) Trace(title string, functionName string, format string, a ...interface{}) {
+	logger.output(Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
+}
+
+// Tracew writes to the Trace destination with fields attached to the line
+func (logger *Logger) Tracew(title string, functionName string, message string, fields ...Field) {
+	logger.output(Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: message, Fields: fields})
+}
+
+// TraceHex writes the bytes to the Trace destination as hex
+func (logger *Logger) TraceHex(title string, functionName string, label string, data []byte) {
+	logger.output(Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: formatHex(label, data)})
+}
+
+//** INFO
+
+// Info writes to the Info destination
+func (logger *Logger) Info(title string, functionName string, format string, a ...interface{}) {
+	logger.output(Record{Level: LEVEL_INFO, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
+}
+
+// Infow writes to the Info destination with fields attached to the line
+func (logger *Logger) Infow(title string, functionName string, message string, fields ...Field) {
+	logger.output(Record{Level: LEVEL_INFO, Title: title, Function: functionName, Tag: "Info", Message: message, Fields: fields})
+}
+
+//** WARNING
+
+// Warning writes to the Warning destination
+func (logger *Logger) Warning(title string, functionName string, format string, a ...interface{}) {
+	logger.output(Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "Info", Message: fmt.Sprintf(format, a...), template: format, args: a})
+}
+
+// Warningw writes to the Warning destination with fields attached to the line
+func (logger *Logger) Warningw(title string, functionName string, message string, fields ...Field) {
+	logger.output(Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "Info", Message: message, Fields: fields})
+}
+
+//** ERROR
+
+// Error writes to the Error destination and accepts an err
+func (logger *Logger) Error(err error, title string, functionName string) {
+	logger.output(Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Err: err, errorCall: true})
+}
+
+// Errorf writes to the Error destination and accepts an err
+func (logger *Logger) Errorf(err error, title string, functionName string, format string, a ...interface{}) {
+	logger.output(Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Message: fmt.Sprintf(format, a...), template: format, args: a, Err: err, errorCall: true})
+}
+
+// Errorw writes to the Error destination, accepts an err and attaches fields to the line
+func (logger *Logger) Errorw(err error, title string, functionName string, message string, fields ...Field) {
+	logger.output(Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Message: message, Err: err, Fields: fields, errorCall: true})
+}
+
+// ErrorStack writes to the Error destination, accepts an err and adds a stack field
+func (logger *Logger) ErrorStack(err error, title string, functionName string) {
+	logger.output(Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ERROR", Err: err, Fields: []Field{{Key: "stack", Value: logger.traceLog.callStack(1)}}, errorCall: true})
+}
+
+//** ALERT
+
+// Alert writes to the Error destination and sends email alert
+func (logger *Logger) Alert(subject string, title string, functionName string, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	written := logger.output(Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "ALERT", Message: message, template: format, args: a})
+	sendAlert("ERROR", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.traceLog.localTag("ALERT"), message))
+
+	// Fail fast only once the alert has been sent.
+	if written {
+		logger.traceLog.failFast()
+	}
+}
+
+// CompletedAlert writes to the Error destination, writes a Completed tag to the log line and sends email alert
+func (logger *Logger) CompletedAlert(subject string, title string, functionName string, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	written := logger.output(Record{Level: LEVEL_ERROR, Title: title, Function: functionName, Tag: "Completed : ALERT", Message: message, template: format, args: a})
+	sendAlert("ERROR", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.traceLog.localTag("Completed : ALERT"), message))
+
+	// Fail fast only once the alert has been sent.
+	if written {
+		logger.traceLog.failFast()
+	}
+}
+
+// WarnAlert writes to the Warning destination and sends email alert
+func (logger *Logger) WarnAlert(subject string, title string, functionName string, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	logger.output(Record{Level: LEVEL_WARN, Title: title, Function: functionName, Tag: "ALERT", Message: message, template: format, args: a})
+	sendAlert("WARNING", subject, fmt.Sprintf("%s : %s : %s : %s\n", title, functionName, logger.traceLog.localTag("ALERT"), message))
+}
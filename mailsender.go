@@ -0,0 +1,105 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/mail"
+	"strings"
+	"sync"
+)
+
+// MailSender delivers a rendered alert email. The message contains the headers
+// and the body produced by the email template.
+type MailSender interface {
+	SendMail(from string, to []string, message []byte) error
+}
+
+// SetMailSender replaces the SMTP delivery of alert emails with the sender, such
+// as a RecordingMailSender in tests. The retries, the throttle and the template
+// still apply. Alerts are sent to the sender even if ConfigureEmail was never
+// called. Pass nil to go back to SMTP.
+func SetMailSender(sender MailSender) {
+	logger.Serialize.Lock()
+	logger.MailSender = sender
+	logger.Serialize.Unlock()
+}
+
+// smtpSender sends the email to the server set by ConfigureEmail.
+type smtpSender struct {
+	configuration *emailConfiguration
+	helo          string
+}
+
+// SendMail implements the MailSender interface.
+func (sender smtpSender) SendMail(from string, to []string, message []byte) error {
+	return sendMail(sender.configuration, sender.helo, message)
+}
+
+//** RECORDING
+
+// SentMail is an email stored by a RecordingMailSender.
+type SentMail struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+	Raw     []byte
+}
+
+// RecordingMailSender keeps the emails in memory instead of sending them, so
+// tests can check the alerts without a mail server.
+//
+//	sender := log.NewRecordingMailSender()
+//	log.SetMailSender(sender)
+//	log.Alert("Disk Full", "main", "Check", "Free[%d]", 0)
+//	if len(sender.Sent()) != 1 { ... }
+type RecordingMailSender struct {
+	mutex sync.Mutex
+	sent  []SentMail
+}
+
+// NewRecordingMailSender returns a sender with no emails.
+func NewRecordingMailSender() *RecordingMailSender {
+	return &RecordingMailSender{}
+}
+
+// SendMail implements the MailSender interface. The subject and the body are
+// parsed from the message, the raw message is kept as well.
+func (sender *RecordingMailSender) SendMail(from string, to []string, message []byte) error {
+	sent := SentMail{
+		From: from,
+		To:   append([]string(nil), to...),
+		Raw:  append([]byte(nil), message...),
+	}
+
+	if msg, err := mail.ReadMessage(bytes.NewReader(message)); err == nil {
+		sent.Subject = msg.Header.Get("Subject")
+		body, _ := ioutil.ReadAll(msg.Body)
+		sent.Body = strings.TrimSpace(string(body))
+	}
+
+	sender.mutex.Lock()
+	sender.sent = append(sender.sent, sent)
+	sender.mutex.Unlock()
+
+	return nil
+}
+
+// Sent returns the emails recorded so far, oldest first.
+func (sender *RecordingMailSender) Sent() []SentMail {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+
+	return append([]SentMail(nil), sender.sent...)
+}
+
+// Reset removes the recorded emails.
+func (sender *RecordingMailSender) Reset() {
+	sender.mutex.Lock()
+	sender.sent = nil
+	sender.mutex.Unlock()
+}
@@ -0,0 +1,72 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// NilErrorPolicy decides what happens to a line from Error, CompletedError or
+// their variants when the err is nil.
+type NilErrorPolicy int32
+
+// The nil error policies.
+const (
+	NilErrorSkip   NilErrorPolicy = iota // Drop the line, the default
+	NilErrorWarn                         // Write the line to the Warning destination
+	NilErrorMarker                       // Write the line with a "(nil error logged)" marker
+)
+
+// nilErrorMarker is added to the message of an error line with a nil err.
+const nilErrorMarker = "(nil error logged)"
+
+// SetNilErrorPolicy changes what happens when a nil err is passed to Error,
+// CompletedError or their variants. By default the line is skipped.
+func SetNilErrorPolicy(policy NilErrorPolicy) {
+	atomic.StoreInt32(&logger.NilErrorPolicy, int32(policy))
+}
+
+// nilError applies the nil error policy to an error record. It reports false
+// when the record must be skipped.
+func (traceLog *traceLog) nilError(rec *Record) bool {
+	if !rec.errorCall || !isNilError(rec.Err) {
+		return true
+	}
+
+	rec.Err = nil
+
+	switch NilErrorPolicy(atomic.LoadInt32(&traceLog.NilErrorPolicy)) {
+	case NilErrorWarn:
+		rec.Level = LEVEL_WARN
+		return true
+
+	case NilErrorMarker:
+		if rec.Message == "" {
+			rec.Message = nilErrorMarker
+		} else {
+			rec.Message += " " + nilErrorMarker
+		}
+		return true
+	}
+
+	return false
+}
+
+// isNilError reports if the err is nil, including a nil pointer stored in the
+// error interface.
+func isNilError(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(err)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// redactedValue replaces the value of fields that must not be logged.
+const redactedValue = "***"
+
+// TraceObject writes to the Trace destination with the exported fields of a struct,
+// or the entries of a map, attached as fields. Struct fields are named by their
+// log tag when present. A tag of "-" skips the field and the redact option masks
+// its value.
+//	type User struct {
+//	    ID       int    `log:"user_id"`
+//	    Password string `log:",redact"`
+//	    Session  string `log:"-"`
+//	}
+func TraceObject(title string, functionName string, message string, obj interface{}) {
+	if !logger.enabled(LEVEL_TRACE) {
+		return
+	}
+
+	logger.output(2, Record{Level: LEVEL_TRACE, Title: title, Function: functionName, Tag: "Info", Message: message, Fields: objectFields(obj)})
+}
+
+// objectFields converts a struct or map into fields. Any other value becomes a
+// single value field.
+func objectFields(obj interface{}) []Field {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return structFields(v)
+
+	case reflect.Map:
+		return mapFields(v)
+
+	case reflect.Invalid:
+		return nil
+	}
+
+	return []Field{{Key: "value", Value: v.Interface()}}
+}
+
+// structFields returns the exported fields of the struct honoring the log tags.
+func structFields(v reflect.Value) []Field {
+	t := v.Type()
+
+	fields := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if structField.PkgPath != "" {
+			continue
+		}
+
+		name := structField.Name
+		redact := false
+
+		if tag, ok := structField.Tag.Lookup("log"); ok {
+			if tag == "-" {
+				continue
+			}
+
+			options := strings.Split(tag, ",")
+			if options[0] != "" {
+				name = options[0]
+			}
+
+			for _, option := range options[1:] {
+				if option == "redact" {
+					redact = true
+				}
+			}
+		}
+
+		if redact {
+			fields = append(fields, Field{Key: name, Value: redactedValue})
+			continue
+		}
+
+		fields = append(fields, Field{Key: name, Value: v.Field(i).Interface()})
+	}
+
+	return fields
+}
+
+// mapFields returns the map entries as fields sorted by key.
+func mapFields(v reflect.Value) []Field {
+	fields := make([]Field, 0, v.Len())
+
+	iter := v.MapRange()
+	for iter.Next() {
+		fields = append(fields, Field{Key: fmt.Sprint(iter.Key().Interface()), Value: iter.Value().Interface()})
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Key < fields[j].Key
+	})
+
+	return fields
+}
@@ -0,0 +1,122 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+/*
+	Package otellog provides context aware variants of the log calls that correlate
+	each line with the current OpenTelemetry span. When the context carries a valid
+	span context, trace_id and span_id fields are appended to the message.
+
+	StartOTLP exports the log records to an OpenTelemetry collector using OTLP
+	over HTTP, so the logs flow through the same pipeline as the traces.
+
+		func handler(ctx context.Context) {
+		    otellog.Info(ctx, "main", "handler", "Processing Order[%d]", id)
+		}
+
+	Output:
+
+		INFO: 2013/11/07 08:24:32 main.go:12: main : handler : Info : Processing Order[42] trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7
+*/
+package otellog
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/finapps/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// callDepth skips the otellog function so the caller's file and line are logged.
+const callDepth = 3
+
+// spanFields returns the trace_id and span_id fields for the span in the context.
+func spanFields(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return ""
+	}
+
+	return fmt.Sprintf("trace_id=%s span_id=%s", spanContext.TraceID(), spanContext.SpanID())
+}
+
+// withSpan appends the span fields to the formatted message.
+func withSpan(ctx context.Context, format string, a ...interface{}) string {
+	message := fmt.Sprintf(format, a...)
+	if fields := spanFields(ctx); fields != "" {
+		return message + " " + fields
+	}
+
+	return message
+}
+
+//** STARTED AND COMPLETED
+
+// Started uses the Trace destination and adds a Started tag to the log line
+func Started(ctx context.Context, title string, functionName string) {
+	if fields := spanFields(ctx); fields != "" {
+		log.Startedfcd(callDepth, title, functionName, "%s", fields)
+		return
+	}
+
+	log.Startedcd(callDepth, title, functionName)
+}
+
+// Completed uses the Trace destination and writes a Completed tag to the log line
+func Completed(ctx context.Context, title string, functionName string) {
+	if fields := spanFields(ctx); fields != "" {
+		log.Completedfcd(callDepth, title, functionName, "%s", fields)
+		return
+	}
+
+	log.Completedcd(callDepth, title, functionName)
+}
+
+// CompletedError uses the Error destination and writes a Completed tag to the log line
+func CompletedError(ctx context.Context, err error, title string, functionName string) {
+	if fields := spanFields(ctx); fields != "" {
+		log.CompletedErrorfcd(callDepth, err, title, functionName, "%s", fields)
+		return
+	}
+
+	log.CompletedErrorcd(callDepth, err, title, functionName)
+}
+
+//** TRACE
+
+// Trace writes to the Trace destination
+func Trace(ctx context.Context, title string, functionName string, format string, a ...interface{}) {
+	log.Tracecd(callDepth, title, functionName, "%s", withSpan(ctx, format, a...))
+}
+
+//** INFO
+
+// Info writes to the Info destination
+func Info(ctx context.Context, title string, functionName string, format string, a ...interface{}) {
+	log.Infocd(callDepth, title, functionName, "%s", withSpan(ctx, format, a...))
+}
+
+//** WARNING
+
+// Warning writes to the Warning destination
+func Warning(ctx context.Context, title string, functionName string, format string, a ...interface{}) {
+	log.Warningcd(callDepth, title, functionName, "%s", withSpan(ctx, format, a...))
+}
+
+//** ERROR
+
+// Error writes to the Error destination and accepts an err
+func Error(ctx context.Context, err error, title string, functionName string) {
+	if fields := spanFields(ctx); fields != "" {
+		log.Errorfcd(callDepth, err, title, functionName, "%s", fields)
+		return
+	}
+
+	log.Errorcd(callDepth, err, title, functionName)
+}
+
+// Errorf writes to the Error destination and accepts an err
+func Errorf(ctx context.Context, err error, title string, functionName string, format string, a ...interface{}) {
+	log.Errorfcd(callDepth, err, title, functionName, "%s", withSpan(ctx, format, a...))
+}
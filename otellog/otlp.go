@@ -0,0 +1,295 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package otellog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/finapps/log"
+)
+
+const (
+	otlpBatchSize     = 512              // Records sent in a single export request
+	otlpFlushInterval = time.Second      // Longest a record waits to be exported
+	otlpQueueSize     = 8192             // Records held while the collector is slow
+	otlpTimeout       = 10 * time.Second // Timeout for a single export request
+)
+
+// StartOTLP initializes tracelog and only displays the specified logging level
+// and exports the same records to an OpenTelemetry collector using OTLP over
+// HTTP. The endpoint is the collector's base url, such as http://localhost:4318,
+// and /v1/logs is added when it has no path. Records are exported in batches,
+// Stop sends the records still waiting.
+func StartOTLP(endpoint string, level int32) error {
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("Endpoint[%s] is not a http or https url", endpoint)
+	}
+
+	if target.Path == "" || target.Path == "/" {
+		target.Path = "/v1/logs"
+	}
+
+	log.Start(level)
+	log.AddSink(level, newOTLPSink(target.String()))
+
+	return nil
+}
+
+// otlpSink batches records and exports them to the collector.
+type otlpSink struct {
+	endpoint string
+	client   *http.Client
+	service  string
+	mutex    sync.Mutex
+	pending  []log.Record
+	dropped  int
+	flush    chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newOTLPSink returns a sink exporting to the endpoint and starts its exporter.
+func newOTLPSink(endpoint string) *otlpSink {
+	sink := otlpSink{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: otlpTimeout},
+		service:  filepath.Base(os.Args[0]),
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go sink.export()
+	return &sink
+}
+
+// WriteRecord implements the log.Sink interface. The record is queued so the
+// log call never waits on the collector.
+func (sink *otlpSink) WriteRecord(rec log.Record) error {
+	sink.mutex.Lock()
+	if len(sink.pending) >= otlpQueueSize {
+		sink.dropped++
+		sink.mutex.Unlock()
+		return nil
+	}
+
+	sink.pending = append(sink.pending, rec)
+	full := len(sink.pending) >= otlpBatchSize
+	sink.mutex.Unlock()
+
+	if full {
+		select {
+		case sink.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Close exports the queued records and stops the exporter.
+func (sink *otlpSink) Close() error {
+	close(sink.stop)
+	<-sink.done
+
+	sink.mutex.Lock()
+	dropped := sink.dropped
+	sink.mutex.Unlock()
+
+	if dropped > 0 {
+		return fmt.Errorf("Dropped[%d] records waiting for the collector", dropped)
+	}
+
+	return nil
+}
+
+// export sends the queued records every flush interval, when a batch is full
+// and when the sink is closed.
+func (sink *otlpSink) export() {
+	defer close(sink.done)
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-sink.flush:
+		case <-sink.stop:
+			sink.send()
+			return
+		}
+
+		sink.send()
+	}
+}
+
+// send exports the queued records in batches. A batch the collector rejects
+// is dropped, the sink is a best effort destination like the console.
+func (sink *otlpSink) send() {
+	sink.mutex.Lock()
+	pending := sink.pending
+	sink.pending = nil
+	sink.mutex.Unlock()
+
+	for len(pending) > 0 {
+		count := len(pending)
+		if count > otlpBatchSize {
+			count = otlpBatchSize
+		}
+
+		if err := sink.post(pending[:count]); err != nil {
+			sink.mutex.Lock()
+			sink.dropped += count
+			sink.mutex.Unlock()
+		}
+
+		pending = pending[count:]
+	}
+}
+
+// post sends one export request with the records.
+func (sink *otlpSink) post(records []log.Record) error {
+	body, err := json.Marshal(sink.request(records))
+	if err != nil {
+		return err
+	}
+
+	response, err := sink.client.Post(sink.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Collector returned Status[%s]", response.Status)
+	}
+
+	return nil
+}
+
+//** OTLP DATA MODEL
+
+// The types below are the parts of the OTLP/JSON logs request that are used.
+
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpValue       `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// request maps the records onto the OTel log data model. The message is the
+// body and the title, function, tag, caller, error and fields are attributes.
+func (sink *otlpSink) request(records []log.Record) otlpRequest {
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	logRecords := make([]otlpLogRecord, 0, len(records))
+	for _, rec := range records {
+		number, text := otlpSeverity(rec.Level)
+
+		attributes := []otlpAttribute{
+			stringAttribute("title", rec.Title),
+			stringAttribute("function", rec.Function),
+			stringAttribute("tag", rec.Tag),
+			stringAttribute("code.location", rec.Caller),
+		}
+
+		if rec.Err != nil {
+			attributes = append(attributes, stringAttribute("exception.message", rec.Err.Error()))
+		}
+
+		for _, field := range rec.Fields {
+			attributes = append(attributes, stringAttribute(field.Key, fmt.Sprint(field.Value)))
+		}
+
+		logRecords = append(logRecords, otlpLogRecord{
+			TimeUnixNano:         strconv.FormatInt(rec.Time.UnixNano(), 10),
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       number,
+			SeverityText:         text,
+			Body:                 otlpValue{StringValue: rec.Message},
+			Attributes:           attributes,
+		})
+	}
+
+	return otlpRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{stringAttribute("service.name", sink.service)},
+			},
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScope{Name: "github.com/finapps/log"},
+				LogRecords: logRecords,
+			}},
+		}},
+	}
+}
+
+// stringAttribute returns an attribute with a string value.
+func stringAttribute(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
+
+// otlpSeverity maps a logging level onto the OTel severity number and text.
+func otlpSeverity(level int32) (int, string) {
+	switch level {
+	case log.LEVEL_TRACE:
+		return 1, "TRACE"
+	case log.LEVEL_INFO:
+		return 9, "INFO"
+	case log.LEVEL_WARN:
+		return 13, "WARN"
+	default:
+		return 17, "ERROR"
+	}
+}
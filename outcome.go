@@ -0,0 +1,29 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+// Outcome is the result of a task written by CompletedResult.
+type Outcome int
+
+// The outcomes of a task.
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomeFailure
+	OutcomeSkipped
+)
+
+// String returns the name written to the outcome field.
+func (outcome Outcome) String() string {
+	switch outcome {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeFailure:
+		return "failure"
+	case OutcomeSkipped:
+		return "skipped"
+	}
+
+	return "unknown"
+}
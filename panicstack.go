@@ -0,0 +1,186 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// defaultPanicStackDepth is the number of frames captured when a panic is
+// reported and no depth has been set.
+const defaultPanicStackDepth = 64
+
+// packagePath is the import path of this package, used to recognize its frames.
+var packagePath = strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(Start).Pointer()).Name(), ".Start")
+
+// PanicError is the error CatchPanic returns for a recovered panic. It keeps
+// the panic value with its type, and unwraps to it when the value is an error.
+type PanicError struct {
+	Function string      // The function name given to CatchPanic
+	Value    interface{} // The value passed to panic
+	Stack    string      // The stack trace of the panic
+}
+
+// Error implements the error interface.
+func (panicError *PanicError) Error() string {
+	return fmt.Sprintf("%s : PANIC : %v", panicError.Function, panicError.Value)
+}
+
+// Unwrap returns the panic value when it's an error.
+func (panicError *PanicError) Unwrap() error {
+	err, _ := panicError.Value.(error)
+	return err
+}
+
+// SetPanicStackDepth limits the number of frames captured by CatchPanic and the
+// middleware when a panic is reported. A depth of 0 or less uses the default of
+// 64 frames.
+func SetPanicStackDepth(n int) {
+	logger.Serialize.Lock()
+	logger.PanicStackDepth = n
+	logger.Serialize.Unlock()
+}
+
+// SetPanicFrameFilter sets a filter for the frames of a reported panic. Only the
+// frames the filter returns true for are written to the stack. Use
+// ApplicationFrames to leave out the standard library and this package. Pass nil
+// to keep every frame.
+func SetPanicFrameFilter(filter func(frame runtime.Frame) bool) {
+	logger.Serialize.Lock()
+	logger.PanicFrameFilter = filter
+	logger.Serialize.Unlock()
+}
+
+// ApplicationFrames is a frame filter that keeps the frames of the application
+// and its dependencies and drops the frames of the standard library and of this
+// package.
+func ApplicationFrames(frame runtime.Frame) bool {
+	function := frame.Function
+
+	if strings.HasPrefix(function, packagePath+".") {
+		return false
+	}
+
+	if strings.HasPrefix(function, "main.") {
+		return true
+	}
+
+	// Standard library packages have no dot in the first element of the path.
+	first := function
+	if i := strings.Index(first, "/"); i >= 0 {
+		first = first[:i]
+	} else if i := strings.Index(first, "."); i >= 0 {
+		first = first[:i]
+	}
+
+	return strings.Contains(first, ".")
+}
+
+// panicStack returns the stack of the goroutine that panicked in the format of
+// runtime.Stack. The frames above the panic are skipped and the depth and the
+// frame filter are applied.
+func (traceLog *traceLog) panicStack() string {
+	all := traceLog.stackFrames(1)
+
+	// Start at the frame that panicked when the stack contains the panic.
+	for i, frame := range all {
+		if frame.Function == "runtime.gopanic" {
+			all = all[i+1:]
+			break
+		}
+	}
+
+	var stack strings.Builder
+	for _, frame := range traceLog.filterFrames(all) {
+		fmt.Fprintf(&stack, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+
+	return stack.String()
+}
+
+// stackFrames returns the frames of the calling goroutine, skipping the frames
+// like runtime.Callers. Extra frames are captured to make up for the ones the
+// caller skips and the ones the filter drops.
+func (traceLog *traceLog) stackFrames(skip int) []runtime.Frame {
+	pcs := make([]uintptr, traceLog.stackDepth()+64)
+	pcs = pcs[:runtime.Callers(skip+1, pcs)]
+
+	var all []runtime.Frame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		all = append(all, frame)
+		if !more {
+			break
+		}
+	}
+
+	return all
+}
+
+// filterFrames applies the frame filter and the depth to the frames.
+func (traceLog *traceLog) filterFrames(all []runtime.Frame) []runtime.Frame {
+	traceLog.Serialize.Lock()
+	filter := traceLog.PanicFrameFilter
+	traceLog.Serialize.Unlock()
+
+	depth := traceLog.stackDepth()
+
+	frames := make([]runtime.Frame, 0, depth)
+	for _, frame := range all {
+		if len(frames) == depth {
+			break
+		}
+
+		if filter != nil && !filter(frame) {
+			continue
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// stackDepth returns the number of frames a stack is limited to.
+func (traceLog *traceLog) stackDepth() int {
+	traceLog.Serialize.Lock()
+	depth := traceLog.PanicStackDepth
+	traceLog.Serialize.Unlock()
+
+	if depth <= 0 {
+		return defaultPanicStackDepth
+	}
+
+	return depth
+}
+
+// callStack returns the frames starting skip frames above the caller of
+// callStack as "function (file.go:12)" entries.
+// When a maximum line length is set the entries stop before they exceed it.
+func (traceLog *traceLog) callStack(skip int) []string {
+	frames := traceLog.filterFrames(traceLog.stackFrames(skip + 2))
+
+	maxLineLength := traceLog.maxLineLength()
+
+	stack := make([]string, 0, len(frames))
+	length := 0
+	for _, frame := range frames {
+		entry := fmt.Sprintf("%s (%s:%d)", funcName(frame.Function), filepath.Base(frame.File), frame.Line)
+
+		length += len(entry) + 1
+		if maxLineLength > 0 && length > maxLineLength {
+			break
+		}
+
+		stack = append(stack, entry)
+	}
+
+	return stack
+}
@@ -0,0 +1,36 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import "sync/atomic"
+
+// Pause drops every line until Resume is called, without changing the logging
+// levels, such as during a noisy maintenance window. Nothing is buffered, the
+// lines are discarded as cheaply as a disabled level. The emails sent by Alert
+// still go out. A line is written to the Info destination before the logging
+// is paused. Starting the logging again ends the pause.
+func Pause() {
+	if atomic.LoadInt32(&logger.Paused) != 0 {
+		return
+	}
+
+	Info("main", "Pause", "Logging Paused")
+	atomic.StoreInt32(&logger.Paused, 1)
+}
+
+// Resume starts writing the lines again after Pause and writes a line to the
+// Info destination to mark where the gap ends.
+func Resume() {
+	if atomic.SwapInt32(&logger.Paused, 0) == 0 {
+		return
+	}
+
+	Info("main", "Resume", "Logging Resumed")
+}
+
+// Paused reports if the logging is paused.
+func Paused() bool {
+	return atomic.LoadInt32(&logger.Paused) != 0
+}
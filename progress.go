@@ -0,0 +1,165 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultProgressPercent  = 10               // Percent of the total between progress lines
+	defaultProgressInterval = 10 * time.Second // Longest time between progress lines
+)
+
+// Progress writes progress lines for a long running loop to the Info
+// destination. A line is written each time another part of the total is done,
+// every 10 percent by default, and when the interval has passed since the last
+// line, every 10 seconds by default, so a slow loop still shows it's alive.
+// Done writes a summary with the elapsed time and the rate. A Progress can be
+// stepped from several goroutines.
+//
+//	progress := log.NewProgress("import", "Run", len(rows))
+//	for _, row := range rows {
+//	    importRow(row)
+//	    progress.Step()
+//	}
+//	progress.Done()
+type Progress struct {
+	title        string
+	functionName string
+	total        int
+
+	mutex    sync.Mutex
+	percent  int
+	interval time.Duration
+	count    int
+	start    time.Time
+	lastLine time.Time
+	nextStep int
+	done     bool
+}
+
+// NewProgress returns a Progress for a loop over total items. A total of 0 or
+// less means the total isn't known, the lines are then only written on the
+// interval.
+func NewProgress(title string, functionName string, total int) *Progress {
+	now := time.Now()
+
+	progress := Progress{
+		title:        title,
+		functionName: functionName,
+		total:        total,
+		percent:      defaultProgressPercent,
+		interval:     defaultProgressInterval,
+		start:        now,
+		lastLine:     now,
+	}
+	progress.nextStep = progress.stepSize()
+
+	return &progress
+}
+
+// SetPercent writes a line each time another percent of the total is done.
+// Pass 0 to only write lines on the interval.
+func (progress *Progress) SetPercent(percent int) {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+
+	progress.percent = percent
+	progress.nextStep = progress.count + progress.stepSize()
+}
+
+// SetInterval writes a line when the interval has passed since the last one.
+// Pass 0 to only write lines on the percentage.
+func (progress *Progress) SetInterval(interval time.Duration) {
+	progress.mutex.Lock()
+	progress.interval = interval
+	progress.mutex.Unlock()
+}
+
+// Step records one item as done.
+func (progress *Progress) Step() {
+	progress.add(1)
+}
+
+// Add records n items as done.
+func (progress *Progress) Add(n int) {
+	progress.add(n)
+}
+
+// Done writes the summary with the number of items done, the elapsed time and
+// the rate. Only the first call writes it.
+func (progress *Progress) Done() {
+	progress.mutex.Lock()
+	if progress.done {
+		progress.mutex.Unlock()
+		return
+	}
+	progress.done = true
+	fields := progress.fields(time.Now())
+	progress.mutex.Unlock()
+
+	logger.output(2, Record{Level: LEVEL_INFO, Title: progress.title, Function: progress.functionName, Tag: "Completed", Fields: fields})
+}
+
+// add counts the items and writes a line when a step of the total has been
+// reached or the interval has passed.
+func (progress *Progress) add(n int) {
+	now := time.Now()
+
+	progress.mutex.Lock()
+	progress.count += n
+
+	stepped := progress.nextStep > 0 && progress.count >= progress.nextStep
+	timed := progress.interval > 0 && now.Sub(progress.lastLine) >= progress.interval
+	if progress.done || (!stepped && !timed) {
+		progress.mutex.Unlock()
+		return
+	}
+
+	if stepped {
+		// Skip the steps passed over by a large Add so only one line is written.
+		step := progress.stepSize()
+		progress.nextStep += step * ((progress.count-progress.nextStep)/step + 1)
+	}
+	progress.lastLine = now
+	fields := progress.fields(now)
+	progress.mutex.Unlock()
+
+	// The caller of Step or Add is the caller of the line.
+	logger.output(3, Record{Level: LEVEL_INFO, Title: progress.title, Function: progress.functionName, Tag: "Progress", Fields: fields})
+}
+
+// stepSize returns the number of items between the percentage lines, 0 when
+// they are off. The mutex must be held by the caller.
+func (progress *Progress) stepSize() int {
+	if progress.total <= 0 || progress.percent <= 0 {
+		return 0
+	}
+
+	step := progress.total * progress.percent / 100
+	if step < 1 {
+		step = 1
+	}
+
+	return step
+}
+
+// fields returns the fields of a progress line. The mutex must be held by the
+// caller.
+func (progress *Progress) fields(now time.Time) []Field {
+	elapsed := now.Sub(progress.start)
+
+	fields := []Field{{Key: "done", Value: progress.count}}
+	if progress.total > 0 {
+		fields = append(fields,
+			Field{Key: "total", Value: progress.total},
+			Field{Key: "percent", Value: progress.count * 100 / progress.total},
+		)
+	}
+
+	return append(fields, Duration("elapsed", elapsed), Rate("rate", progress.count, elapsed))
+}
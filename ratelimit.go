@@ -0,0 +1,78 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// callerRateLimiter limits the number of lines each call site can write per window.
+type callerRateLimiter struct {
+	mutex sync.Mutex
+	limit int
+	per   time.Duration
+	sites map[string]*siteWindow
+}
+
+// siteWindow tracks the lines written by a call site in the current window.
+type siteWindow struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// SetPerCallerRateLimit limits every call site to n lines per duration. Lines
+// over the limit are dropped and counted, and the next line written by the site
+// carries a suppressed field with the count. A value of 0 removes the limit.
+func SetPerCallerRateLimit(n int, per time.Duration) {
+	var limiter *callerRateLimiter
+	if n > 0 && per > 0 {
+		limiter = &callerRateLimiter{
+			limit: n,
+			per:   per,
+			sites: make(map[string]*siteWindow),
+		}
+	}
+
+	logger.Serialize.Lock()
+	logger.RateLimiter = limiter
+	logger.Serialize.Unlock()
+}
+
+// SuppressedLines returns the number of lines dropped by the per caller rate limit.
+func SuppressedLines() int64 {
+	return atomic.LoadInt64(&logger.SuppressedLines)
+}
+
+// allow reports if the call site may write a line now. When a new window starts
+// it also returns how many lines the site had suppressed in the previous windows.
+func (limiter *callerRateLimiter) allow(site string, now time.Time) (bool, int) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	window, ok := limiter.sites[site]
+	if !ok {
+		window = &siteWindow{start: now}
+		limiter.sites[site] = window
+	}
+
+	if now.Sub(window.start) >= limiter.per {
+		window.start = now
+		window.count = 0
+	}
+
+	if window.count >= limiter.limit {
+		window.suppressed++
+		return false, 0
+	}
+
+	window.count++
+
+	suppressed := window.suppressed
+	window.suppressed = 0
+	return true, suppressed
+}
@@ -0,0 +1,81 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redactPattern replaces the matches of a regular expression in messages.
+type redactPattern struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// RegisterRedactKey masks the value of any field with the key, ignoring case,
+// before the record is written. The key is also matched in the form the key
+// policy gives it, so user.password still masks a field renamed user_password.
+func RegisterRedactKey(key string) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	// Records are redacted outside the lock, so replace the map instead of
+	// changing it.
+	keys := make(map[string]struct{}, len(logger.RedactKeys)+1)
+	for k := range logger.RedactKeys {
+		keys[k] = struct{}{}
+	}
+	keys[strings.ToLower(key)] = struct{}{}
+	keys[strings.ToLower(replaceInvalidKey(key))] = struct{}{}
+
+	logger.RedactKeys = keys
+}
+
+// RegisterRedactPattern replaces every match of the regular expression in the
+// message with the replacement before the record is written. The replacement
+// can refer to submatches like regexp.ReplaceAllString.
+func RegisterRedactPattern(re *regexp.Regexp, replacement string) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	patterns := make([]redactPattern, 0, len(logger.RedactPatterns)+1)
+	patterns = append(patterns, logger.RedactPatterns...)
+	logger.RedactPatterns = append(patterns, redactPattern{re: re, replacement: replacement})
+}
+
+// redact applies the registered keys and patterns to the record. The Serialize
+// lock must be held by the caller.
+func (traceLog *traceLog) redact(rec Record) Record {
+	return redactRecord(rec, traceLog.RedactKeys, traceLog.RedactPatterns)
+}
+
+// redactRecord applies the keys and patterns to the record. The fields are
+// copied before they are changed since the caller may share them.
+func redactRecord(rec Record, keys map[string]struct{}, patterns []redactPattern) Record {
+	if len(keys) > 0 {
+		var fields []Field
+		for i, field := range rec.Fields {
+			if _, ok := keys[strings.ToLower(field.Key)]; !ok {
+				continue
+			}
+
+			if fields == nil {
+				fields = append([]Field(nil), rec.Fields...)
+			}
+			fields[i].Value = redactedValue
+		}
+
+		if fields != nil {
+			rec.Fields = fields
+		}
+	}
+
+	for _, pattern := range patterns {
+		rec.Message = pattern.re.ReplaceAllString(rec.Message, pattern.replacement)
+	}
+
+	return rec
+}
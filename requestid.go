@@ -0,0 +1,79 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// requestIDs holds the request id of each goroutine that set one.
+	requestIDs sync.Map
+
+	// requestIDCount is the number of goroutines with a request id so log calls
+	// skip the lookup when there are none.
+	requestIDCount int64
+)
+
+// WithRequestID adds a request_id field to every line written by the calling
+// goroutine until the returned function is called, which restores the previous
+// id. This is for code that doesn't pass a context.Context around.
+//
+//	defer log.WithRequestID(r.Header.Get("X-Request-ID"))()
+//
+// The id belongs to the goroutine, not the request. Goroutines started by the
+// caller don't inherit it and must set their own, and a goroutine that is reused,
+// such as one in a worker pool, keeps the id until the returned function is called.
+func WithRequestID(id string) func() {
+	goid := goroutineID()
+
+	previous, hadPrevious := requestIDs.Load(goid)
+	if !hadPrevious {
+		atomic.AddInt64(&requestIDCount, 1)
+	}
+	requestIDs.Store(goid, id)
+
+	return func() {
+		if hadPrevious {
+			requestIDs.Store(goid, previous)
+			return
+		}
+
+		requestIDs.Delete(goid)
+		atomic.AddInt64(&requestIDCount, -1)
+	}
+}
+
+// requestID returns the request id of the calling goroutine.
+func requestID() (string, bool) {
+	if atomic.LoadInt64(&requestIDCount) == 0 {
+		return "", false
+	}
+
+	id, ok := requestIDs.Load(goroutineID())
+	if !ok {
+		return "", false
+	}
+
+	return id.(string), true
+}
+
+// goroutineID returns the id of the calling goroutine from the header of its
+// stack trace, "goroutine 18 [running]:".
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
@@ -0,0 +1,200 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RetentionPolicy removes old log files from the base file path. It runs when
+// the log file is opened and every time it's rotated.
+type RetentionPolicy interface {
+	Apply(baseFilePath string) error
+}
+
+// retentionPolicy is the policy set by SetRetentionPolicy.
+var retentionPolicy RetentionPolicy
+
+// SetRetentionPolicy replaces the removal of date directories older than the
+// daysToKeep passed to StartFile with the policy. Call it before StartFile,
+// passing nil restores the default.
+//
+//	log.SetRetentionPolicy(log.CountRetention{MaxFiles: 20})
+func SetRetentionPolicy(policy RetentionPolicy) {
+	retentionPolicy = policy
+}
+
+// applyRetention runs the retention policy, or the date directory cleanup when
+// none is set.
+func (traceLog *traceLog) applyRetention(baseFilePath string, daysToKeep int) {
+	if retentionPolicy == nil {
+		traceLog.LogDirectoryCleanup(baseFilePath, daysToKeep)
+		return
+	}
+
+	if err := retentionPolicy.Apply(baseFilePath); err != nil {
+		internalErrorf(err, "RetentionPolicy", "BaseFilePath[%s]", baseFilePath)
+	}
+}
+
+//** AGE
+
+// AgeRetention removes the date directories older than DaysToKeep. This is what
+// StartFile does when no policy is set.
+type AgeRetention struct {
+	DaysToKeep int
+}
+
+// Apply implements the RetentionPolicy interface.
+func (ageRetention AgeRetention) Apply(baseFilePath string) error {
+	logger.LogDirectoryCleanup(baseFilePath, ageRetention.DaysToKeep)
+	return nil
+}
+
+//** COUNT
+
+// CountRetention keeps the newest MaxFiles log files and removes the rest. A
+// MaxFiles of 0 or less keeps every file.
+type CountRetention struct {
+	MaxFiles int
+}
+
+// Apply implements the RetentionPolicy interface.
+func (countRetention CountRetention) Apply(baseFilePath string) error {
+	files, err := logFiles(baseFilePath)
+	if err != nil {
+		return err
+	}
+
+	if countRetention.MaxFiles <= 0 || len(files) <= countRetention.MaxFiles {
+		return nil
+	}
+
+	return removeLogFiles(baseFilePath, files[:len(files)-countRetention.MaxFiles])
+}
+
+//** SIZE
+
+// SizeRetention removes the oldest log files until the files left add up to no
+// more than MaxBytes. A MaxBytes of 0 or less keeps every file.
+type SizeRetention struct {
+	MaxBytes int64
+}
+
+// Apply implements the RetentionPolicy interface.
+func (sizeRetention SizeRetention) Apply(baseFilePath string) error {
+	if sizeRetention.MaxBytes <= 0 {
+		return nil
+	}
+
+	files, err := logFiles(baseFilePath)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+
+	remove := 0
+	for remove < len(files) && total > sizeRetention.MaxBytes {
+		total -= files[remove].size
+		remove++
+	}
+
+	return removeLogFiles(baseFilePath, files[:remove])
+}
+
+// logFile is a log file found in a date directory.
+type logFile struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+// logFiles returns the log files in the date directories, oldest first. The
+// files the logging system has open are left out so they are never removed.
+func logFiles(baseFilePath string) ([]logFile, error) {
+	open := openLogFiles()
+
+	directories, err := ioutil.ReadDir(baseFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []logFile
+	for _, directory := range directories {
+		if !directory.IsDir() {
+			continue
+		}
+
+		fileInfos, err := ioutil.ReadDir(filepath.Join(baseFilePath, directory.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fileInfo := range fileInfos {
+			path := filepath.Join(baseFilePath, directory.Name(), fileInfo.Name())
+			if fileInfo.IsDir() || open[path] {
+				continue
+			}
+
+			files = append(files, logFile{path: path, size: fileInfo.Size(), modTime: fileInfo.ModTime().UnixNano()})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime != files[j].modTime {
+			return files[i].modTime < files[j].modTime
+		}
+		return files[i].path < files[j].path
+	})
+
+	return files, nil
+}
+
+// openLogFiles returns the paths of the files the logging system has open.
+func openLogFiles() map[string]bool {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	open := make(map[string]bool)
+	if logger.LogFile != nil {
+		open[filepath.Clean(logger.LogFile.Name())] = true
+	}
+
+	for _, logf := range logger.LevelFiles {
+		open[filepath.Clean(logf.Name())] = true
+	}
+
+	return open
+}
+
+// removeLogFiles removes the files and any date directory left empty.
+func removeLogFiles(baseFilePath string, files []logFile) error {
+	directories := make(map[string]bool)
+
+	for _, file := range files {
+		Trace("main", "RetentionPolicy", "Removing File[%s]", file.path)
+
+		if err := os.Remove(file.path); err != nil {
+			return err
+		}
+
+		directories[filepath.Dir(file.path)] = true
+	}
+
+	for directory := range directories {
+		if fileInfos, err := ioutil.ReadDir(directory); err == nil && len(fileInfos) == 0 {
+			os.Remove(directory)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSizeRetention checks that the oldest files are removed down to MaxBytes
+// and that a MaxBytes of 0 or less keeps every file.
+func TestSizeRetention(t *testing.T) {
+	tests := []struct {
+		maxBytes int64
+		left     int
+	}{
+		{-1, 3},
+		{0, 3},
+		{25, 2},
+		{1000, 3},
+	}
+
+	for _, tt := range tests {
+		baseFilePath := t.TempDir()
+		directory := filepath.Join(baseFilePath, "2013-01-01")
+		if err := os.Mkdir(directory, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{"a.log", "b.log", "c.log"} {
+			if err := ioutil.WriteFile(filepath.Join(directory, name), make([]byte, 10), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := (SizeRetention{MaxBytes: tt.maxBytes}).Apply(baseFilePath); err != nil {
+			t.Fatalf("SizeRetention{MaxBytes: %d}.Apply : %s", tt.maxBytes, err)
+		}
+
+		files, _ := filepath.Glob(filepath.Join(directory, "*"))
+		if len(files) != tt.left {
+			t.Errorf("SizeRetention{MaxBytes: %d} left %d files, want %d", tt.maxBytes, len(files), tt.left)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// StartFileRolling initializes tracelog like StartFileSplit and starts a new
+// file every day and whenever the file grows past maxFileBytes within the day.
+// The files are numbered within each day's directory and the numbering starts
+// again the next day. Date directories older than daysToKeep are removed.
+//
+//	2013-11-07/2013-11-07.001.log
+//	2013-11-07/2013-11-07.002.log
+//	2013-11-08/2013-11-08.001.log
+func StartFileRolling(consoleLevel int32, fileLevel int32, baseFilePath string, daysToKeep int, maxFileBytes int64) {
+	startFile(consoleLevel, fileLevel, baseFilePath, daysToKeep, fileOptions{rolling: &rollingFile{maxBytes: maxFileBytes}})
+}
+
+// rollingFile tracks the day, the sequence number and the size of the current
+// file. Everything but written is protected by the Serialize lock.
+type rollingFile struct {
+	maxBytes int64
+	written  int64
+	day      string
+	sequence int
+	nextDay  time.Time
+}
+
+// create creates the next numbered file for today, the sequence starts at 1 on
+// a new day. Numbers already used by an earlier run are skipped.
+func (rolling *rollingFile) create(baseFilePath string, ext string) (*os.File, error) {
+	now := time.Now().In(fileLocation)
+	day := now.Format("2006-01-02")
+	if day != rolling.day {
+		rolling.day = day
+		rolling.sequence = 0
+	}
+
+	filePath := fmt.Sprintf("%s/%s/", baseFilePath, day)
+	if err := os.MkdirAll(filePath, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("Failed to Create log directory : %s : %s", filePath, err)
+	}
+
+	for {
+		rolling.sequence++
+		name := fmt.Sprintf("%s%s.%03d.%s", filePath, day, rolling.sequence, ext)
+
+		logf, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			year, month, date := now.Date()
+			rolling.nextDay = time.Date(year, month, date+1, 0, 0, 0, 0, now.Location())
+			atomic.StoreInt64(&rolling.written, 0)
+			return logf, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("Failed to Create log file : %s : %s", name, err)
+		}
+	}
+}
+
+// roll replaces the log file when the day has changed or the file has reached
+// the size limit. The Serialize lock must be held by the caller, so nothing is
+// writing to the file being closed.
+func (traceLog *traceLog) roll(now time.Time) {
+	rolling := traceLog.Rolling
+	if rolling == nil || traceLog.LogFile == nil {
+		return
+	}
+
+	newDay := !now.Before(rolling.nextDay)
+	if !newDay && (rolling.maxBytes <= 0 || atomic.LoadInt64(&rolling.written) < rolling.maxBytes) {
+		return
+	}
+
+	// The file can't be closed under an abandoned write, roll on a later line.
+	if traceLog.fileBusy() {
+		return
+	}
+
+	logf, err := rolling.create(traceLog.BaseFilePath, traceLog.logExtension())
+	if err != nil {
+		// Keep the current file and try again later rather than on every line.
+		// The error is written once the lock has been released.
+		atomic.StoreInt64(&rolling.written, 0)
+		rolling.nextDay = now.Add(time.Minute)
+		go internalErrorf(err, "Roll", "BaseFilePath[%s]", traceLog.BaseFilePath)
+		return
+	}
+
+	levelFiles, err := createLevelFiles(traceLog.BaseFilePath)
+	if err != nil {
+		levelFiles = traceLog.LevelFiles
+	}
+
+	previous, previousLevelFiles := traceLog.swapLogFile(logf, levelFiles)
+	previous.Close()
+	if err == nil {
+		closeLevelFiles(previousLevelFiles)
+	}
+
+	// The cleanup writes to the log, so it can't run while the lock is held.
+	if newDay {
+		go traceLog.applyRetention(traceLog.BaseFilePath, traceLog.DaysToKeep)
+	}
+}
+
+// rollingCounter counts the bytes written to the rolling file.
+type rollingCounter struct {
+	writer  io.Writer
+	rolling *rollingFile
+}
+
+// Write implements the io.Writer interface.
+func (counter *rollingCounter) Write(p []byte) (int, error) {
+	n, err := counter.writer.Write(p)
+	atomic.AddInt64(&counter.rolling.written, int64(n))
+	return n, err
+}
@@ -0,0 +1,126 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"os"
+)
+
+// RotateNow closes the current log file and opens a new timestamped file in
+// place of it. The directory cleanup runs after the new file is in use.
+func RotateNow() error {
+	Started("main", "RotateNow")
+
+	logger.Serialize.Lock()
+
+	if logger.LogFile == nil {
+		logger.Serialize.Unlock()
+		err := errors.New("no log file to rotate")
+		internalCompletedError(err, "RotateNow")
+		return err
+	}
+
+	// The file can't be closed under an abandoned write.
+	if !logger.waitForFile(defaultDrainTimeout) {
+		logger.Serialize.Unlock()
+		err := errors.New("log file is busy with an abandoned write")
+		internalCompletedError(err, "RotateNow")
+		return err
+	}
+
+	logf, err := logger.createFile()
+	if err != nil {
+		logger.Serialize.Unlock()
+		internalCompletedError(err, "RotateNow")
+		return err
+	}
+
+	levelFiles, err := createLevelFiles(logger.BaseFilePath)
+	if err != nil {
+		logf.Close()
+		logger.Serialize.Unlock()
+		internalCompletedError(err, "RotateNow")
+		return err
+	}
+
+	previous, previousLevelFiles := logger.swapLogFile(logf, levelFiles)
+
+	baseFilePath := logger.BaseFilePath
+	daysToKeep := logger.DaysToKeep
+
+	logger.Serialize.Unlock()
+
+	// Every write holds the Serialize lock and none was abandoned on the files,
+	// so nothing is writing to the previous file.
+	if err := previous.Close(); err != nil {
+		internalCompletedError(err, "RotateNow")
+		return err
+	}
+
+	if err := closeLevelFiles(previousLevelFiles); err != nil {
+		internalCompletedError(err, "RotateNow")
+		return err
+	}
+
+	logger.applyRetention(baseFilePath, daysToKeep)
+
+	Completedf("main", "RotateNow", "File[%s]", logf.Name())
+	return nil
+}
+
+// createFile creates the next log file, numbered within the day when rolling.
+// The Serialize lock must be held by the caller.
+func (traceLog *traceLog) createFile() (*os.File, error) {
+	if traceLog.Rolling != nil {
+		return traceLog.Rolling.create(traceLog.BaseFilePath, traceLog.logExtension())
+	}
+
+	return createLogFile(traceLog.BaseFilePath, traceLog.logExtension())
+}
+
+// logExtension returns the extension of the log file, with .gz added when the
+// file is compressed.
+func (traceLog *traceLog) logExtension() string {
+	if traceLog.Compress {
+		return fileExtension + ".gz"
+	}
+
+	return fileExtension
+}
+
+// swapLogFile makes the new files the destination of the writes and returns the
+// previous ones for the caller to close. The Serialize lock must be held by the
+// caller.
+func (traceLog *traceLog) swapLogFile(logf *os.File, levelFiles map[int32]*os.File) (*os.File, map[int32]*os.File) {
+	previous := traceLog.LogFile
+	previousLevelFiles := traceLog.LevelFiles
+	traceLog.LogFile = logf
+	traceLog.LevelFiles = levelFiles
+	traceLog.FileHandle = logf
+
+	if traceLog.FileBuffer != nil {
+		traceLog.FileBuffer.Flush()
+	}
+
+	// The previous file gets the end of its compressed stream before it's closed.
+	if traceLog.GzipWriter != nil {
+		traceLog.GzipWriter.Close()
+		traceLog.GzipWriter = gzip.NewWriter(logf)
+		traceLog.FileHandle = traceLog.GzipWriter
+	}
+
+	if traceLog.FileBuffer != nil {
+		traceLog.FileBuffer = bufio.NewWriter(traceLog.FileHandle)
+		traceLog.FileHandle = traceLog.FileBuffer
+	}
+
+	traceLog.setHandles(traceLog.ConsoleLevel, traceLog.FileLevel)
+	traceLog.writeFileHeader(previousLevelFiles)
+
+	return previous, previousLevelFiles
+}
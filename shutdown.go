@@ -0,0 +1,49 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SetShutdownEmail has Shutdown send a "service stopping" email with the reason
+// when email is configured.
+func SetShutdownEmail(enabled bool) {
+	logger.Serialize.Lock()
+	logger.ShutdownEmail = enabled
+	logger.Serialize.Unlock()
+}
+
+// Shutdown writes the reason for stopping, sends the shutdown email when
+// SetShutdownEmail is on, and then stops the logging system like Stop. Every
+// error, not just the first, is returned joined together.
+//
+//	defer log.Shutdown("received SIGTERM")
+func Shutdown(reason string) error {
+	Info("main", "Shutdown", "Reason[%s]", reason)
+
+	var errs []error
+
+	logger.Serialize.Lock()
+	email := logger.ShutdownEmail && logger.EmailConfiguration != nil
+	logger.Serialize.Unlock()
+
+	if email {
+		host, _ := os.Hostname()
+		program := filepath.Base(os.Args[0])
+
+		subject := fmt.Sprintf("Service Stopping : %s : %s", program, host)
+		if err := sendEmail("INFO", subject, fmt.Sprintf("Reason[%s]\n", reason)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, stopLogging()...)
+
+	return errors.Join(errs...)
+}
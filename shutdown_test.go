@@ -0,0 +1,127 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// closeSink keeps the messages of the records written to it and notes any write
+// made after it was closed.
+type closeSink struct {
+	mutex            sync.Mutex
+	messages         map[string]bool
+	closed           bool
+	writesAfterClose int
+}
+
+// WriteRecord implements the Sink interface.
+func (closeSink *closeSink) WriteRecord(rec Record) error {
+	closeSink.mutex.Lock()
+	defer closeSink.mutex.Unlock()
+
+	if closeSink.closed {
+		closeSink.writesAfterClose++
+		return nil
+	}
+
+	closeSink.messages[rec.Message] = true
+	return nil
+}
+
+// Close implements the io.Closer interface.
+func (closeSink *closeSink) Close() error {
+	closeSink.mutex.Lock()
+	closeSink.closed = true
+	closeSink.mutex.Unlock()
+
+	return nil
+}
+
+// TestStopWhileLogging logs from several goroutines while Stop runs. Every line
+// logged before Stop was called must reach the sink and the file, and nothing
+// may be written to the sink after it was closed.
+func TestStopWhileLogging(t *testing.T) {
+	baseFilePath := t.TempDir()
+	StartFileSplit(0, LEVEL_INFO, baseFilePath, 0)
+
+	sink := &closeSink{messages: make(map[string]bool)}
+	AddSink(LEVEL_INFO, sink)
+	SetAsync(64)
+
+	const goroutines, lines = 4, 500
+
+	var stopping int32
+	lastBeforeStop := make([]int, goroutines)
+	started := make(chan struct{}, goroutines)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		lastBeforeStop[g] = -1
+
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < lines; n++ {
+				Info("main", "TestStopWhileLogging", "Goroutine[%d] Line[%d]", g, n)
+
+				// The line was logged before Stop was called.
+				if atomic.LoadInt32(&stopping) == 0 {
+					lastBeforeStop[g] = n
+				}
+
+				if n == lines/10 {
+					started <- struct{}{}
+				}
+			}
+		}(g)
+	}
+
+	for g := 0; g < goroutines; g++ {
+		<-started
+	}
+
+	atomic.StoreInt32(&stopping, 1)
+	if err := Stop(); err != nil {
+		t.Fatalf("Stop : %s", err)
+	}
+	wg.Wait()
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	if sink.writesAfterClose != 0 {
+		t.Errorf("%d records were written to the sink after it was closed", sink.writesAfterClose)
+	}
+
+	files, err := filepath.Glob(filepath.Join(baseFilePath, "*", "*"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("found log files %v, %v, want one", files, err)
+	}
+
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for g, last := range lastBeforeStop {
+		for n := 0; n <= last; n++ {
+			line := fmt.Sprintf("Goroutine[%d] Line[%d]", g, n)
+			if !sink.messages[line] {
+				t.Fatalf("the sink is missing %s", line)
+			}
+
+			if !strings.Contains(string(data), line+"\n") {
+				t.Fatalf("the file is missing %s", line)
+			}
+		}
+	}
+}
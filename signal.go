@@ -0,0 +1,92 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"os"
+	"os/signal"
+)
+
+// signalToggle switches the logging level between a baseline and TRACE.
+type signalToggle struct {
+	signals      chan os.Signal
+	stop         chan struct{}
+	on           bool
+	consoleLevel int32
+	fileLevel    int32
+}
+
+// InstallSignalToggle switches the logging level to TRACE when the signal, such
+// as syscall.SIGUSR1, is received and back to the previous level when it's
+// received again.
+//
+//	kill -USR1 <pid>
+//
+// A level set with SetLogLevel while TRACE is on becomes the new baseline on the
+// next signal. Installing another signal replaces the toggle and Stop removes it.
+func InstallSignalToggle(sig os.Signal) {
+	toggle := signalToggle{
+		signals: make(chan os.Signal, 1),
+		stop:    make(chan struct{}),
+	}
+
+	logger.Serialize.Lock()
+	logger.removeSignalToggle()
+	logger.SignalToggle = &toggle
+	logger.Serialize.Unlock()
+
+	signal.Notify(toggle.signals, sig)
+
+	go func() {
+		for {
+			select {
+			case <-toggle.signals:
+				toggle.flip()
+			case <-toggle.stop:
+				return
+			}
+		}
+	}()
+}
+
+// flip switches between the baseline level and TRACE.
+func (signalToggle *signalToggle) flip() {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	// A level set while TRACE was on replaces the baseline.
+	if signalToggle.on && (logger.ConsoleLevel != LEVEL_TRACE || logger.FileLevel != LEVEL_TRACE) {
+		signalToggle.on = false
+	}
+
+	// The toggle replaces any boost in progress like SetLogLevel does.
+	if logger.BoostTimer != nil {
+		logger.BoostTimer.Stop()
+		logger.BoostTimer = nil
+	}
+
+	if signalToggle.on {
+		signalToggle.on = false
+		logger.setHandles(signalToggle.consoleLevel, signalToggle.fileLevel)
+		return
+	}
+
+	signalToggle.on = true
+	signalToggle.consoleLevel = logger.ConsoleLevel
+	signalToggle.fileLevel = logger.FileLevel
+	logger.setHandles(LEVEL_TRACE, LEVEL_TRACE)
+}
+
+// removeSignalToggle stops listening for the toggle signal. The Serialize lock
+// must be held by the caller.
+func (traceLog *traceLog) removeSignalToggle() {
+	if traceLog.SignalToggle == nil {
+		return
+	}
+
+	signal.Stop(traceLog.SignalToggle.signals)
+	close(traceLog.SignalToggle.stop)
+	traceLog.SignalToggle = nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Sink receives every record written at a logging level enabled for the sink.
+// Sinks are written in addition to the console and file destinations.
+type Sink interface {
+	WriteRecord(rec Record) error
+}
+
+// sinkEntry pairs a registered sink with its logging level.
+type sinkEntry struct {
+	logLevel int32
+	sink     Sink
+	busy     *int32
+}
+
+// AddSink registers a sink that receives the records enabled by the logging level.
+// Sinks that implement io.Closer are closed by Stop.
+func AddSink(logLevel int32, sink Sink) {
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	logger.Sinks = append(logger.Sinks, sinkEntry{logLevel: logLevel, sink: sink, busy: new(int32)})
+	atomic.StoreInt32(&logger.SinkLevel, logger.SinkLevel|logLevel)
+}
+
+// levelEnabled reports if the logging level mask enables the level. Like the
+// destinations, a level enables itself and every level more severe than it.
+func levelEnabled(logLevel int32, level int32) bool {
+	return logLevel&(level|(level-1)) != 0
+}
+
+// writerSink encodes records and writes them to an io.Writer.
+type writerSink struct {
+	writer  io.Writer
+	encoder Encoder
+}
+
+// NewWriterSink returns a sink that writes records to the writer using the encoder.
+func NewWriterSink(writer io.Writer, encoder Encoder) Sink {
+	return &writerSink{writer: writer, encoder: encoder}
+}
+
+// WriteRecord implements the Sink interface.
+func (writerSink *writerSink) WriteRecord(rec Record) error {
+	_, err := writerSink.writer.Write(writerSink.encoder.Encode(rec))
+	return err
+}
+
+// Close closes the writer if it supports closing.
+func (writerSink *writerSink) Close() error {
+	if closer, ok := writerSink.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
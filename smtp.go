@@ -0,0 +1,78 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// SetEmailHELO sets the host name sent in the SMTP HELO/EHLO greeting. Some
+// relays reject the "localhost" name used by default.
+func SetEmailHELO(name string) {
+	logger.Serialize.Lock()
+	logger.EmailHELO = name
+	logger.Serialize.Unlock()
+}
+
+// sendMail works like smtp.SendMail but greets the server with the configured
+// HELO name.
+func sendMail(configuration *emailConfiguration, helo string, message []byte) error {
+	client, err := smtp.Dial(net.JoinHostPort(configuration.Host, strconv.Itoa(configuration.Port)))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if helo != "" {
+		if err := client.Hello(helo); err != nil {
+			return err
+		}
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: configuration.Host}); err != nil {
+			return err
+		}
+	}
+
+	if configuration.Auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+
+		if err := client.Auth(configuration.Auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(configuration.UserName); err != nil {
+		return err
+	}
+
+	for _, to := range configuration.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
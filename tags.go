@@ -0,0 +1,46 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+// DefaultTags returns the English tags written by the logging calls. The keys
+// are the tags SetTags accepts.
+func DefaultTags() map[string]string {
+	return map[string]string{
+		"Started":           "Started",
+		"Completed":         "Completed",
+		"Completed : ERROR": "Completed : ERROR",
+		"Completed : ALERT": "Completed : ALERT",
+		"Info":              "Info",
+		"ERROR":             "ERROR",
+		"ALERT":             "ALERT",
+	}
+}
+
+// SetTags replaces the English tags with localized ones. The keys are the
+// English tags from DefaultTags and any tag left out keeps its English text.
+//
+//	log.SetTags(map[string]string{"Started": "Gestartet", "Completed": "Beendet"})
+func SetTags(tags map[string]string) {
+	localized := make(map[string]string, len(tags))
+	for tag, text := range tags {
+		localized[tag] = text
+	}
+
+	logger.Serialize.Lock()
+	logger.Tags = localized
+	logger.Serialize.Unlock()
+}
+
+// localTag returns the localized text for the tag.
+func (traceLog *traceLog) localTag(tag string) string {
+	traceLog.Serialize.Lock()
+	defer traceLog.Serialize.Unlock()
+
+	if text, ok := traceLog.Tags[tag]; ok {
+		return text
+	}
+
+	return tag
+}
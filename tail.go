@@ -0,0 +1,224 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// subscriberBuffer is the number of lines queued for a slow subscriber
+// before new lines are dropped for it.
+const subscriberBuffer = 256
+
+// ringBuffer is a sink that retains the most recent lines and pushes new
+// lines to any subscribers.
+type ringBuffer struct {
+	mutex       sync.Mutex
+	logLevel    int32
+	lines       [][]byte
+	records     []Record
+	next        int
+	full        bool
+	encoder     Encoder
+	subscribers map[chan []byte]struct{}
+}
+
+// EnableRingBuffer retains the last size lines enabled by the logging level in
+// memory. The lines are available to TailHandler clients when they connect.
+// A size of 0 or less retains no lines, clients only receive the new ones.
+func EnableRingBuffer(logLevel int32, size int) {
+	if size < 0 {
+		size = 0
+	}
+
+	ring := &ringBuffer{
+		logLevel:    logLevel,
+		lines:       make([][]byte, size),
+		records:     make([]Record, size),
+		encoder:     TextEncoder{},
+		subscribers: make(map[chan []byte]struct{}),
+	}
+
+	logger.Serialize.Lock()
+	logger.Ring = ring
+	logger.Serialize.Unlock()
+
+	AddSink(logLevel, ring)
+}
+
+// WriteRecord implements the Sink interface.
+func (ring *ringBuffer) WriteRecord(rec Record) error {
+	line := ring.encoder.Encode(rec)
+
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	if len(ring.lines) > 0 {
+		ring.lines[ring.next] = line
+		ring.records[ring.next] = rec
+		ring.next = (ring.next + 1) % len(ring.lines)
+		if ring.next == 0 {
+			ring.full = true
+		}
+	}
+
+	for subscriber := range ring.subscribers {
+		select {
+		case subscriber <- line:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// snapshot returns the retained lines from oldest to newest.
+func (ring *ringBuffer) snapshot() [][]byte {
+	if !ring.full {
+		return append([][]byte(nil), ring.lines[:ring.next]...)
+	}
+
+	return append(append([][]byte(nil), ring.lines[ring.next:]...), ring.lines[:ring.next]...)
+}
+
+// retained returns the retained records from oldest to newest.
+func (ring *ringBuffer) retained() []Record {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	if !ring.full {
+		return append([]Record(nil), ring.records[:ring.next]...)
+	}
+
+	return append(append([]Record(nil), ring.records[ring.next:]...), ring.records[:ring.next]...)
+}
+
+// ReplayBuffer writes the records retained by the ring buffer to the current
+// destinations and sinks, encoded with the current format. Use it after
+// starting again with a file to get the lines written before the file existed.
+// The console receives the lines a second time.
+//
+//	log.Start(log.LEVEL_TRACE)
+//	log.EnableRingBuffer(log.LEVEL_TRACE, 1000)
+//	...
+//	log.StartFile(log.LEVEL_TRACE, logPath, 7)
+//	log.ReplayBuffer()
+func ReplayBuffer() error {
+	logger.Serialize.Lock()
+	ring := logger.Ring
+	logger.Serialize.Unlock()
+
+	if ring == nil {
+		return errors.New("ring buffer is not enabled")
+	}
+
+	for _, rec := range ring.retained() {
+		logger.Serialize.Lock()
+		logger.replay(rec, ring)
+		logger.Serialize.Unlock()
+	}
+
+	return nil
+}
+
+// replay writes a record to the destination for its level and the sinks other
+// than the ring it came from. The Serialize lock must be held by the caller.
+func (traceLog *traceLog) replay(rec Record, ring *ringBuffer) {
+	if handle := traceLog.handle(rec.Level); handle != nil && handle != ioutil.Discard {
+		line := applyLineEnding(traceLog.consoleEncoder().Encode(rec), traceLog.LineEnding)
+		if _, err := handle.Write(line); err != nil && traceLog.Fallback != nil {
+			traceLog.Fallback.Write(line)
+		}
+	}
+
+	if fileHandle := traceLog.fileHandle(rec.Level); fileHandle != nil && fileHandle != ioutil.Discard {
+		line := applyLineEnding(traceLog.fileEncoder().Encode(rec), traceLog.LineEnding)
+		if _, err := fileHandle.Write(line); err != nil && traceLog.Fallback != nil {
+			traceLog.Fallback.Write(line)
+		}
+	}
+
+	for _, entry := range traceLog.Sinks {
+		if entry.sink != Sink(ring) && levelEnabled(entry.logLevel, rec.Level) {
+			entry.sink.WriteRecord(rec)
+		}
+	}
+}
+
+// subscribe returns the retained lines and a channel that receives new lines.
+func (ring *ringBuffer) subscribe() ([][]byte, chan []byte) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	subscriber := make(chan []byte, subscriberBuffer)
+	ring.subscribers[subscriber] = struct{}{}
+
+	return ring.snapshot(), subscriber
+}
+
+// unsubscribe stops sending lines to the subscriber.
+func (ring *ringBuffer) unsubscribe(subscriber chan []byte) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	delete(ring.subscribers, subscriber)
+}
+
+// TailHandler streams the log to the client using Server-Sent Events. The lines
+// retained by the ring buffer are sent first, followed by each new line as it is
+// written. EnableRingBuffer must be called before clients connect.
+func TailHandler(w http.ResponseWriter, r *http.Request) {
+	logger.Serialize.Lock()
+	ring := logger.Ring
+	logger.Serialize.Unlock()
+
+	if ring == nil {
+		http.Error(w, "ring buffer is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lines, subscriber := ring.subscribe()
+	defer ring.unsubscribe(subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for _, line := range lines {
+		writeEvent(w, line)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case line := <-subscriber:
+			writeEvent(w, line)
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes the line as a Server-Sent Event. Each line of a multi-line
+// message is sent as its own data field.
+func writeEvent(w http.ResponseWriter, line []byte) {
+	for _, data := range bytes.Split(bytes.TrimRight(line, "\r\n"), []byte("\n")) {
+		fmt.Fprintf(w, "data: %s\n", data)
+	}
+	fmt.Fprint(w, "\n")
+}
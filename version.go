@@ -0,0 +1,28 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import "sync/atomic"
+
+// version holds the build version added to every record.
+var version atomic.Value
+
+// SetVersion adds a version field with the build version to every record, so a
+// line can be traced to the build that wrote it. Call it once at startup, the
+// version is read without a lock on every log call. Pass "" to stop adding it.
+//
+//	// go build -ldflags "-X main.version=1.4.2"
+//	var version = "dev"
+//
+//	log.SetVersion(version)
+func SetVersion(v string) {
+	version.Store(v)
+}
+
+// buildVersion returns the version set by SetVersion.
+func buildVersion() string {
+	v, _ := version.Load().(string)
+	return v
+}
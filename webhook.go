@@ -0,0 +1,120 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+)
+
+// defaultWebhookScript is the payload posted to the webhook. It fits the Slack
+// and Teams incoming webhooks.
+const defaultWebhookScript = `{"text":{{json (printf "%s on %s : %s\n%s" .Severity .Host .Subject .Message)}}}`
+
+// webhookTimeout limits how long a webhook post may take.
+const webhookTimeout = 10 * time.Second
+
+// webhookConfiguration contains the webhook alerts are posted to.
+type webhookConfiguration struct {
+	URL      string
+	Template *template.Template
+}
+
+// webhookFuncs are the functions available to the webhook template. The json
+// function quotes a value for use in the JSON payload.
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ConfigureWebhook posts every alert sent by email, such as the ones from Alert
+// and SetEmailOnLevel, to the webhook URL as well. The alerts share the email
+// throttle and are posted in the background so a slow webhook never blocks a log
+// call. The payload fits Slack and Teams, use SetWebhookTemplate for others.
+func ConfigureWebhook(url string) {
+	configuration := webhookConfiguration{
+		URL:      url,
+		Template: template.Must(template.New("webhookTemplate").Funcs(webhookFuncs).Parse(defaultWebhookScript)),
+	}
+
+	logger.Serialize.Lock()
+	logger.Webhook = &configuration
+	logger.Serialize.Unlock()
+}
+
+// SetWebhookTemplate replaces the payload posted to the webhook. The template has
+// the same values as the email template, Severity, Subject, Message, Host and
+// Timestamp, plus a json function to quote a value.
+//
+//	{"text":{{json .Subject}}}
+func SetWebhookTemplate(script string) error {
+	tmpl, err := template.New("webhookTemplate").Funcs(webhookFuncs).Parse(script)
+	if err != nil {
+		return err
+	}
+
+	logger.Serialize.Lock()
+	defer logger.Serialize.Unlock()
+
+	if logger.Webhook == nil {
+		return fmt.Errorf("webhook is not configured")
+	}
+
+	logger.Webhook = &webhookConfiguration{URL: logger.Webhook.URL, Template: tmpl}
+	return nil
+}
+
+// postWebhook posts the alert to the configured webhook.
+func postWebhook(severity string, subject string, message string) error {
+	var err error
+	defer logger.CatchPanic(&err, "PostWebhook")
+
+	logger.Serialize.Lock()
+	configuration := logger.Webhook
+	logger.Serialize.Unlock()
+
+	if configuration == nil {
+		return err
+	}
+
+	host, _ := os.Hostname()
+
+	parameters := emailParameters{
+		Subject:   subject,
+		Message:   message,
+		Severity:  severity,
+		Host:      host,
+		Timestamp: time.Now().UTC(),
+	}
+
+	var payload bytes.Buffer
+	if err = configuration.Template.Execute(&payload, &parameters); err != nil {
+		internalErrorf(err, "PostWebhook", "Rendering Payload Subject[%s]", subject)
+		return err
+	}
+
+	client := http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(configuration.URL, "application/json", &payload)
+	if err != nil {
+		internalErrorf(err, "PostWebhook", "Posting Subject[%s]", subject)
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		err = fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		internalErrorf(err, "PostWebhook", "Posting Subject[%s]", subject)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriteTimeout is returned for a write to a destination or sink that didn't
+// finish within the write timeout.
+var ErrWriteTimeout = errors.New("log write timed out")
+
+// SetWriteTimeout abandons a write to a destination or sink that takes longer
+// than the timeout so a stuck writer can't stall all logging. The line is sent
+// to the fallback writer instead. Until the abandoned write returns, later writes
+// to the same destination or sink fail right away. Network sinks also use the
+// timeout as their write deadline. Pass 0 to wait for every write, which is the
+// default.
+func SetWriteTimeout(d time.Duration) {
+	atomic.StoreInt64(&logger.WriteTimeout, int64(d))
+}
+
+// writeTimeout returns the timeout set by SetWriteTimeout.
+func (traceLog *traceLog) writeTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&traceLog.WriteTimeout))
+}
+
+// timedWrite runs the write and gives up on it after the write timeout. The busy
+// flag stays set while an abandoned write is still running.
+func (traceLog *traceLog) timedWrite(busy *int32, write func() error) error {
+	timeout := traceLog.writeTimeout()
+	if timeout <= 0 {
+		return write()
+	}
+
+	if !atomic.CompareAndSwapInt32(busy, 0, 1) {
+		return ErrWriteTimeout
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := write()
+		atomic.StoreInt32(busy, 0)
+		done <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrWriteTimeout
+	}
+}
+
+// fileIdlePoll is how often a file with an abandoned write is checked before
+// it's closed.
+const fileIdlePoll = 10 * time.Millisecond
+
+// destination is a file or the console, shared by the level handles writing to
+// it. Writes are given up after the write timeout.
+type destination struct {
+	writer io.Writer
+	busy   *int32
+	file   bool
+}
+
+// Write implements the io.Writer interface.
+func (destination *destination) Write(p []byte) (int, error) {
+	err := logger.timedWrite(destination.busy, func() error {
+		_, err := destination.writer.Write(p)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// destination returns the destination writing to the writer, registered in the
+// destinations under the key. The busy flag of the destination already in use
+// for the key is kept, so a write abandoned before the handles were rebuilt
+// still holds up the next. Keys that can't be map keys get their own flag. The
+// Serialize lock must be held by the caller.
+func (traceLog *traceLog) destination(destinations map[io.Writer]*destination, key io.Writer, writer io.Writer, file bool) *destination {
+	comparable := reflect.TypeOf(key).Comparable()
+	if comparable {
+		if existing, ok := destinations[key]; ok {
+			return existing
+		}
+	}
+
+	created := &destination{writer: writer, busy: new(int32), file: file}
+	if comparable {
+		if previous, ok := traceLog.Destinations[key]; ok {
+			created.busy = previous.busy
+		}
+		destinations[key] = created
+	}
+
+	return created
+}
+
+// fileBusy reports if an abandoned write is still running on a log file. The
+// Serialize lock must be held by the caller.
+func (traceLog *traceLog) fileBusy() bool {
+	for _, destination := range traceLog.Destinations {
+		if destination.file && atomic.LoadInt32(destination.busy) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitForFile waits for the abandoned writes on the log files to return so they
+// can be closed, giving up after the timeout. It reports if the files are idle.
+// The Serialize lock must be held by the caller, it's released while waiting.
+func (traceLog *traceLog) waitForFile(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for traceLog.fileBusy() {
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		traceLog.Serialize.Unlock()
+		time.Sleep(fileIdlePoll)
+		traceLog.Serialize.Lock()
+	}
+
+	return true
+}
+
+// setWriteDeadline sets the write deadline of a network connection from the
+// write timeout.
+func setWriteDeadline(conn net.Conn) {
+	if timeout := logger.writeTimeout(); timeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+}
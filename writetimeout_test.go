@@ -0,0 +1,75 @@
+// Copyright 2013 Ardan Studios. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE handle.
+
+package log
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowWriter takes the delay to write and counts the writes that overlapped.
+type slowWriter struct {
+	delay    time.Duration
+	active   int32
+	overlaps int32
+}
+
+// Write implements the io.Writer interface.
+func (slowWriter *slowWriter) Write(p []byte) (int, error) {
+	if atomic.AddInt32(&slowWriter.active, 1) > 1 {
+		atomic.AddInt32(&slowWriter.overlaps, 1)
+	}
+
+	time.Sleep(slowWriter.delay)
+	atomic.AddInt32(&slowWriter.active, -1)
+
+	return len(p), nil
+}
+
+// TestWriteTimeoutSharedFile abandons a write to the file on the Error level,
+// which also writes to the console, and then writes to the file on the Trace
+// level. The second write must not run while the first is still writing, and
+// Stop must wait for the abandoned write.
+func TestWriteTimeoutSharedFile(t *testing.T) {
+	SetWriteTimeout(20 * time.Millisecond)
+	defer SetWriteTimeout(0)
+
+	file := &slowWriter{delay: 200 * time.Millisecond}
+	turnOnLogging(LEVEL_ERROR, LEVEL_TRACE, file)
+
+	Errorf(nil, "main", "TestWriteTimeoutSharedFile", "Abandoned")
+	for i := 0; i < 3; i++ {
+		Trace("main", "TestWriteTimeoutSharedFile", "Line[%d]", i)
+	}
+
+	Stop()
+
+	if active := atomic.LoadInt32(&file.active); active != 0 {
+		t.Errorf("Stop returned with %d writes still running", active)
+	}
+
+	if overlaps := atomic.LoadInt32(&file.overlaps); overlaps != 0 {
+		t.Errorf("%d writes to the file overlapped", overlaps)
+	}
+}
+
+// TestDestinationsPruned checks that rebuilding the handles for a new file only
+// keeps the destinations in use.
+func TestDestinationsPruned(t *testing.T) {
+	turnOnLogging(LEVEL_ERROR, LEVEL_TRACE, &slowWriter{})
+	defer turnOnLogging(0, 0, nil)
+
+	for i := 0; i < 5; i++ {
+		logger.Serialize.Lock()
+		logger.FileHandle = &slowWriter{}
+		logger.setHandles(LEVEL_ERROR, LEVEL_TRACE)
+		logger.Serialize.Unlock()
+	}
+
+	if n := len(logger.Destinations); n != 3 {
+		t.Errorf("%d destinations, want stdout, stderr and the file", n)
+	}
+}